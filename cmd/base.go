@@ -10,45 +10,110 @@ import (
 type baseHelmCmd struct {
 	namespace string
 
-	chart      string
-	values     []string
-	valueFiles []string
+	chart        string
+	manifests    []string
+	values       []string
+	valueFiles   []string
+	stringValues []string
+	fileValues   []string
+	jsonValues   []string
 
-	remote  bool
-	require bool
+	version     string
+	repo        string
+	releaseName string
+	apiVersions []string
+	kubeVersion string
+	includeCRDs bool
+	showOnly    []string
+
+	remote           bool
+	require          bool
+	requireEphemeral bool
+	nodes            int32
+	// nodeCounts counts nodes matching DaemonSet node selector, GetNodeCount is used when not set
+	nodeCounts func(selector map[string]string) (int32, error)
+	// offline forbids cluster lookups, e.g. when quotas are loaded from files
+	offline bool
+
+	concurrentJobs int32
+	workloadConfig string
+	// workloads is content of --workload-config, read by loadWorkloads
+	workloads *WorkloadConfig
+	scale     string
+
+	defaultStorageClass string
+	// storageClass discovers default storage class of claims not defining one when --default-storage-class is not set
+	storageClass func() string
+	engine       string
+
+	autoscalers map[string]autoscaler
+	limitRanges []cv1.LimitRange
 
 	defaultCpuLimit string
 	defaultMemLimit string
 	defaultCpuReq   string
 	defaultMemReq   string
+
+	defaultEphemeralLimit string
+	defaultEphemeralReq   string
 }
 
 func (b baseHelmCmd) getDefault(k cv1.ResourceName, role string) string {
 	if role == "limit" {
-		if k == cv1.ResourceCPU {
+		switch k {
+		case cv1.ResourceCPU:
 			return b.defaultCpuLimit
+		case cv1.ResourceMemory:
+			return b.defaultMemLimit
+		case cv1.ResourceEphemeralStorage:
+			return b.defaultEphemeralLimit
 		}
-		return b.defaultMemLimit
 	} else {
-		if k == cv1.ResourceCPU {
+		switch k {
+		case cv1.ResourceCPU:
 			return b.defaultCpuReq
+		case cv1.ResourceMemory:
+			return b.defaultMemReq
+		case cv1.ResourceEphemeralStorage:
+			return b.defaultEphemeralReq
 		}
-		return b.defaultMemReq
 	}
+	return ""
 }
 
 func (b *baseHelmCmd) propogateCmdFlags(cmd *cobra.Command) *cobra.Command {
 	f := cmd.Flags()
 	f.StringArrayVar(&b.values, "set", []string{}, "set values on the command line (can specify multiple or separate values with commas: key1=val1,key2=val2)")
 	f.StringArrayVarP(&b.valueFiles, "values", "f", []string{}, "specify values in a YAML file (can specify multiple)")
+	f.StringArrayVar(&b.stringValues, "set-string", []string{}, "set STRING values on the command line (can specify multiple or separate values with commas: key1=val1,key2=val2)")
+	f.StringArrayVar(&b.fileValues, "set-file", []string{}, "set values from respective files specified via the command line (can specify multiple or separate values with commas: key1=path1,key2=path2)")
+	f.StringArrayVar(&b.jsonValues, "set-json", []string{}, "set JSON values on the command line (can specify multiple or separate values with commas: key1=jsonval1,key2=jsonval2)")
+	f.StringVar(&b.version, "version", "", "specify a version constraint for the chart version to use")
+	f.StringVar(&b.repo, "repo", "", "chart repository url where to locate the requested chart")
+	f.StringVar(&b.releaseName, "release-name", "", "release name used to render templates (release-name if not set)")
+	f.StringSliceVar(&b.apiVersions, "api-versions", []string{}, "Kubernetes api versions used for Capabilities.APIVersions")
+	f.StringVar(&b.kubeVersion, "kube-version", "", "Kubernetes version used for Capabilities.KubeVersion")
+	f.BoolVar(&b.includeCRDs, "include-crds", false, "include CRDs in the templated output")
+	f.StringArrayVar(&b.showOnly, "show-only", []string{}, "only count manifests rendered from the given templates")
 
+	f.StringArrayVar(&b.manifests, "manifest", []string{}, "Read rendered manifests from a file or directory instead of chart, - reads stdin (can specify multiple)")
 	f.BoolVar(&b.remote, "remote", false, "Calculate for remote release instand of local chart")
 	f.BoolVar(&b.require, "require", false, "Require CPU and Memory values to be defined for each container.")
+	f.BoolVar(&b.requireEphemeral, "require-ephemeral-storage", false, "Require Ephemeral storage values to be defined for each container.")
 
 	f.StringVar(&b.defaultCpuLimit, "default-cpu-limit", "", "Default value for CPU limit")
 	f.StringVar(&b.defaultMemLimit, "default-mem-limit", "", "Default value for Memory limit")
 	f.StringVar(&b.defaultCpuReq, "default-cpu-req", "", "Default value for CPU request")
 	f.StringVar(&b.defaultMemReq, "default-mem-req", "", "Default value for Memory request")
+	f.StringVar(&b.defaultEphemeralLimit, "default-ephemeral-storage-limit", "", "Default value for Ephemeral storage limit")
+	f.StringVar(&b.defaultEphemeralReq, "default-ephemeral-storage-req", "", "Default value for Ephemeral storage request")
+	f.Int32Var(&b.concurrentJobs, "concurrent-jobs", 1, "Number of overlapping runs to budget for CronJobs allowing concurrent runs")
+	f.StringVar(&b.scale, "scale", scaleCurrent, "Replicas of autoscaled workloads: current, min or max of HorizontalPodAutoscaler range")
+	f.StringVar(&b.workloadConfig, "workload-config", "", "YAML file mapping custom resource kinds to their pod templates")
+	f.StringVar(&b.defaultStorageClass, "default-storage-class", "", "Storage class of claims not defining one (looked up in cluster by check when such claim is found if not set)")
+	f.Int32Var(&b.nodes, "nodes", 0, "Number of nodes DaemonSet pods run on (nodes matching DaemonSet node selector are counted in cluster if not set)")
+	f.StringVar(&b.engine, "engine", engineAuto, "Rendering engine: sdk renders in-process, exec runs helm binary, auto uses sdk falling back to helm binary")
 	f.StringVar(&b.namespace, "namespace", os.Getenv("HELM_NAMESPACE"), "Namespace")
+	kube.addFlags(f)
 	return cmd
 }
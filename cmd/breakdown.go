@@ -0,0 +1,287 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	cv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+type breakdownCmd struct {
+	baseHelmCmd
+	output string
+	sortBy string
+}
+
+// BreakdownReport is versioned output of requirements per workload and container
+type BreakdownReport struct {
+	APIVersion string           `json:"apiVersion"`
+	Kind       string           `json:"kind"`
+	Scale      string           `json:"scale,omitempty"`
+	Workloads  []WorkloadReport `json:"workloads"`
+	Claims     []ClaimReport    `json:"claims"`
+}
+
+// WorkloadReport is contribution of a single workload, total is per pod requirements multiplied by replicas
+type WorkloadReport struct {
+	Kind       string                   `json:"kind"`
+	Name       string                   `json:"name"`
+	Type       string                   `json:"type"`
+	Replicas   int32                    `json:"replicas"`
+	PerPod     cv1.ResourceRequirements `json:"perPod"`
+	Total      cv1.ResourceRequirements `json:"total"`
+	Containers []ContainerReport        `json:"containers"`
+}
+
+// ContainerReport is contribution of a single container of workload
+type ContainerReport struct {
+	Name   string                   `json:"name"`
+	Init   bool                     `json:"init,omitempty"`
+	PerPod cv1.ResourceRequirements `json:"perPod"`
+	Total  cv1.ResourceRequirements `json:"total"`
+}
+
+// ClaimReport is storage of PersistentVolumeClaim or StatefulSet volume claim template, total is per claim storage
+// multiplied by replicas
+type ClaimReport struct {
+	Kind         string            `json:"kind"`
+	Name         string            `json:"name"`
+	Template     string            `json:"template,omitempty"`
+	StorageClass string            `json:"storageClass,omitempty"`
+	Replicas     int32             `json:"replicas"`
+	PerClaim     resource.Quantity `json:"perClaim"`
+	Total        resource.Quantity `json:"total"`
+}
+
+func newBreakdownCommand() *cobra.Command {
+	breakdown := breakdownCmd{}
+
+	cmd := &cobra.Command{
+		Use:   "breakdown",
+		Short: "Show resource requirements per workload and container",
+		Long:  rootCmdLongUsage,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 && len(breakdown.manifests) == 0 {
+				return errors.New("requires an argument: chart path or release name, or --manifest")
+			}
+			return nil
+		},
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return validateOutput(breakdown.output, outputTable, outputJSON, outputYAML)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 0 {
+				breakdown.chart = args[0]
+			}
+			return breakdown.run()
+		},
+	}
+	breakdown.propogateCmdFlags(cmd)
+	f := cmd.Flags()
+	f.StringVar(&breakdown.output, "output", "", "Output format: table (default), json or yaml")
+	f.StringVar(&breakdown.sortBy, "sort-by", "", "Resource to sort workloads and containers by in descending order, e.g. requests.cpu or limits.memory (manifest order if not set)")
+	return cmd
+}
+
+func (b breakdownCmd) run() error {
+	if err := b.loadWorkloads(); err != nil {
+		return err
+	}
+	req, err := b.GetRequirements()
+	if err != nil {
+		return err
+	}
+	return b.FormatOutput(os.Stdout, req)
+}
+
+// workloadType names workload bucket
+func workloadType(bucket string) string {
+	switch bucket {
+	case dsPrefix:
+		return "daemonset"
+	case jobPrefix:
+		return "job"
+	}
+	return "static"
+}
+
+// sortResource parses sort key, resource names without limits. or requests. prefix are requests as in ResourceQuota
+func sortResource(key string) (bool, cv1.ResourceName) {
+	if strings.HasPrefix(key, cv1.DefaultResourceRequestsPrefix) {
+		return false, cv1.ResourceName(strings.TrimPrefix(key, cv1.DefaultResourceRequestsPrefix))
+	}
+	if strings.HasPrefix(key, "limits.") {
+		return true, cv1.ResourceName(strings.TrimPrefix(key, "limits."))
+	}
+	return false, cv1.ResourceName(key)
+}
+
+// total multiplies per pod requirements by replicas
+func total(rr cv1.ResourceRequirements, repl int32) cv1.ResourceRequirements {
+	res := cv1.ResourceRequirements{
+		Limits:   cv1.ResourceList{},
+		Requests: cv1.ResourceList{},
+	}
+	addPod("", rr, &res, repl)
+	return res
+}
+
+// report returns workloads, except rollout surge, and claims in manifest order or sorted by total of sort resource
+func (b breakdownCmd) report(req *Requirements) BreakdownReport {
+	report := BreakdownReport{
+		APIVersion: reportAPIVersion,
+		Kind:       "Breakdown",
+		Scale:      b.scale,
+		Workloads:  []WorkloadReport{},
+		Claims:     []ClaimReport{},
+	}
+	for _, w := range req.Workloads {
+		if w.Bucket == surgePrefix {
+			continue
+		}
+		wr := WorkloadReport{
+			Kind:       w.Kind,
+			Name:       w.Name,
+			Type:       workloadType(w.Bucket),
+			Replicas:   w.Replicas,
+			PerPod:     w.Pod,
+			Total:      total(w.Pod, w.Replicas),
+			Containers: []ContainerReport{},
+		}
+		for _, c := range w.Containers {
+			wr.Containers = append(wr.Containers, ContainerReport{
+				Name:   c.Name,
+				Init:   c.Init,
+				PerPod: c.Resources,
+				Total:  total(c.Resources, w.Replicas),
+			})
+		}
+		report.Workloads = append(report.Workloads, wr)
+	}
+	for _, c := range req.Claims {
+		t := c.Storage.DeepCopy()
+		t.Mul(int64(c.Replicas))
+		report.Claims = append(report.Claims, ClaimReport{
+			Kind:         c.Kind,
+			Name:         c.Name,
+			Template:     c.Template,
+			StorageClass: c.StorageClass,
+			Replicas:     c.Replicas,
+			PerClaim:     c.Storage,
+			Total:        t,
+		})
+	}
+
+	if b.sortBy != "" {
+		limit, r := sortResource(b.sortBy)
+		value := func(rr cv1.ResourceRequirements) resource.Quantity {
+			if limit {
+				return rr.Limits[r]
+			}
+			return rr.Requests[r]
+		}
+		greater := func(a, b cv1.ResourceRequirements) bool {
+			va, vb := value(a), value(b)
+			return va.Cmp(vb) > 0
+		}
+		sort.SliceStable(report.Workloads, func(i, j int) bool {
+			return greater(report.Workloads[i].Total, report.Workloads[j].Total)
+		})
+		for _, w := range report.Workloads {
+			sort.SliceStable(w.Containers, func(i, j int) bool {
+				return greater(w.Containers[i].Total, w.Containers[j].Total)
+			})
+		}
+		if !limit && r == cv1.ResourceStorage {
+			sort.SliceStable(report.Claims, func(i, j int) bool {
+				return report.Claims[i].Total.Cmp(report.Claims[j].Total) > 0
+			})
+		}
+	}
+	return report
+}
+
+func (b breakdownCmd) FormatOutput(w io.Writer, req *Requirements) error {
+	report := b.report(req)
+	if isStructuredOutput(b.output) {
+		return writeReport(w, b.output, report)
+	}
+
+	rows := reportRows(req)
+	line := func() error {
+		if _, err := fmt.Fprint(w, "+------------------------------+------------------+-----------+----------+"+strings.Repeat("---------------+", len(rows))+"\n"); err != nil {
+			return err
+		}
+		return nil
+	}
+	values := func(rr cv1.ResourceRequirements) string {
+		sb := strings.Builder{}
+		for _, r := range rows {
+			v := r.list(&Requirements{ResourceRequirements: rr})[r.resource]
+			fmt.Fprintf(&sb, " %13v |", &v)
+		}
+		return sb.String()
+	}
+
+	if err := line(); err != nil {
+		return err
+	}
+	header := strings.Builder{}
+	for _, r := range rows {
+		fmt.Fprintf(&header, " %-13.13s |", r.title)
+	}
+	if _, err := fmt.Fprintf(w, "| Workload                     | Container        | Type      | Replicas |%s\n", header.String()); err != nil {
+		return err
+	}
+	if err := line(); err != nil {
+		return err
+	}
+	for _, wr := range report.Workloads {
+		if _, err := fmt.Fprintf(w, "|%-30.30s|                  | %-9.9s | %8d |%s\n", wr.Kind+"/"+wr.Name, wr.Type, wr.Replicas, values(wr.Total)); err != nil {
+			return err
+		}
+		for _, c := range wr.Containers {
+			name := c.Name
+			if c.Init {
+				name = "init:" + name
+			}
+			if _, err := fmt.Fprintf(w, "|                              |%-18.18s|           |          |%s\n", name, values(c.Total)); err != nil {
+				return err
+			}
+		}
+	}
+	if err := line(); err != nil {
+		return err
+	}
+	if len(report.Claims) == 0 {
+		return nil
+	}
+
+	cline := func() error {
+		if _, err := fmt.Fprint(w, "+------------------------------+------------------+------------------+----------+---------------+---------------+\n"); err != nil {
+			return err
+		}
+		return nil
+	}
+	if err := cline(); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprint(w, "| Claim                        | Template         | Storage class    | Replicas | Per claim     | Total         |\n"); err != nil {
+		return err
+	}
+	if err := cline(); err != nil {
+		return err
+	}
+	for _, c := range report.Claims {
+		if _, err := fmt.Fprintf(w, "|%-30.30s|%-18.18s|%-18.18s| %8d | %13v | %13v |\n", c.Kind+"/"+c.Name, c.Template, c.StorageClass, c.Replicas, &c.PerClaim, &c.Total); err != nil {
+			return err
+		}
+	}
+	return cline()
+}
@@ -3,6 +3,7 @@ package cmd
 import (
 	"errors"
 	"fmt"
+	"io"
 	"os"
 
 	"github.com/spf13/cobra"
@@ -11,6 +12,9 @@ import (
 
 type checkCmd struct {
 	baseHelmCmd
+	release    string
+	quotaFiles []string
+	output     string
 }
 
 func newCheckCommand() *cobra.Command {
@@ -21,118 +25,182 @@ func newCheckCommand() *cobra.Command {
 		Short: "Check chart resource requirements with cluster quotas",
 		Long:  rootCmdLongUsage,
 		Args: func(cmd *cobra.Command, args []string) error {
-			if len(args) == 0 {
-				return errors.New("requires an argument: chart path or release name")
+			if len(args) == 0 && len(check.manifests) == 0 {
+				return errors.New("requires an argument: chart path or release name, or --manifest")
 			}
 			return nil
 		},
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return validateOutput(check.output, outputTable, outputJSON, outputYAML)
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			check.chart = args[0]
+			if len(args) > 0 {
+				check.chart = args[0]
+			}
+			cmd.SilenceUsage = true
 			return check.run()
 		},
 	}
 
-	return check.propogateCmdFlags(cmd)
+	check.propogateCmdFlags(cmd)
+	f := cmd.Flags()
+	f.StringVar(&check.release, "release", "", "Deployed release upgraded by the chart, its footprint is excluded from quota usage (the release itself with --remote)")
+	f.StringVar(&check.output, "output", "", "Output format: table (default), json or yaml")
+	f.StringArrayVar(&check.quotaFiles, "quota-file", []string{}, "Check against ResourceQuotas and LimitRanges from a manifest file instead of cluster, - reads stdin (can specify multiple)")
+	return cmd
 }
 
 func (c checkCmd) run() error {
-	q, err := GetQuota(c.namespace)
+	if err := stdinOnce(c.manifests, c.quotaFiles); err != nil {
+		return notEvaluated(err)
+	}
+	if err := c.loadWorkloads(); err != nil {
+		return notEvaluated(err)
+	}
+	q, err := c.quota()
 	if err != nil {
-		return err
+		return notEvaluated(err)
 	}
 	req, err := c.GetRequirements()
 	if err != nil {
-		return err
+		return notEvaluated(err)
 	}
+	own, err := c.ownRequirements(req)
+	if err != nil {
+		return notEvaluated(err)
+	}
+	if err := c.FormatOutput(os.Stdout, q, req, own); err != nil {
+		return notEvaluated(err)
+	}
+	return verdict(q, req, own)
+}
 
-	jobCpuReq := req.Requests[jobCpu]
-	jobCpuLim := req.Limits[jobCpu]
-	jobMemReq := req.Requests[jobMemory]
-	jobMemLim := req.Limits[jobMemory]
-	jobStrReq := req.Requests[jobStorage]
-
-	sumCpuReq := req.Requests.Cpu().DeepCopy()
-	sumCpuReq.Add(jobCpuReq)
-	sumMemReq := req.Requests.Memory().DeepCopy()
-	sumMemReq.Add(jobMemReq)
-	sumStrReq := req.Requests.Storage().DeepCopy()
-	sumStrReq.Add(jobStrReq)
-
-	sumCpuLim := req.Limits.Cpu().DeepCopy()
-	sumCpuLim.Add(jobCpuLim)
-	sumMemLim := req.Limits.Memory().DeepCopy()
-	sumMemLim.Add(jobMemLim)
-
-	qCpuLim := q.Status.Hard[cv1.ResourceLimitsCPU]
-	cpuLimOk := req.Limits.Cpu().Cmp(qCpuLim) < 0
-	cpuSumLimOk := sumCpuLim.Cmp(qCpuLim) < 0
-
-	qMemLim := q.Status.Hard[cv1.ResourceLimitsMemory]
-	memLimOk := req.Limits.Memory().Cmp(qMemLim) < 0
-	memSumLimOk := sumMemLim.Cmp(qMemLim) < 0
-
-	qCpuReq := q.Status.Hard[cv1.ResourceRequestsCPU]
-	cpuReqOk := req.Requests.Cpu().Cmp(qCpuReq) < 0
-	cpuSumReqOk := sumCpuReq.Cmp(qCpuReq) < 0
-
-	qMemReq := q.Status.Hard[cv1.ResourceRequestsMemory]
-	memReqOk := req.Requests.Memory().Cmp(qMemReq) < 0
-	memSumReqOk := sumMemReq.Cmp(qMemReq) < 0
+// verdict returns error with exit code telling whether requirements fit quota permanently, only without jobs,
+// only until rollout or not at all. Object counts do not depend on jobs and LimitRange violations prevent workloads
+// from being created.
+func verdict(q *Quota, req *Requirements, own *Requirements) error {
+	static, sum, rollout := len(req.Violations) == 0, true, true
+	for _, r := range resourceRows(req) {
+		st := q.Check(r, req, own)
+		static = static && st.Static
+		sum = sum && st.Sum
+		rollout = rollout && st.Rollout
+	}
+	for _, r := range countRows(req) {
+		static = static && q.Check(r, req, own).Sum
+	}
+	switch {
+	case !static:
+		return Error{errors.New("requirements do not fit quota"), ExitNotFit}
+	case !sum:
+		return Error{errors.New("requirements do not fit quota while jobs are running"), ExitNotFitJobs}
+	case !rollout:
+		return Error{errors.New("requirements do not fit quota during rollout"), ExitNotFitRollout}
+	}
+	return nil
+}
 
-	qStrReq := q.Status.Hard[cv1.ResourceRequestsStorage]
-	strReqOk := req.Requests.Storage().Cmp(qStrReq) < 0
-	strSumReqOk := sumStrReq.Cmp(qStrReq) < 0
+// quota loads namespace quota and LimitRanges from quota files or from cluster, discovering default storage class there
+func (c *checkCmd) quota() (*Quota, error) {
+	if len(c.quotaFiles) > 0 {
+		q, lr, err := LoadQuotaFiles(c.quotaFiles, c.namespace)
+		if err != nil {
+			return nil, err
+		}
+		c.limitRanges = lr
+		c.offline = true
+		return q, nil
+	}
+	q, err := GetQuota(c.namespace)
+	if err != nil {
+		return nil, err
+	}
+	if c.defaultStorageClass == "" {
+		c.storageClass = discoverDefaultStorageClass()
+	}
+	if c.limitRanges, err = GetLimitRanges(c.namespace); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
 
-	w := os.Stdout
+// FormatOutput writes requirements compared with quota, own is footprint of upgraded release
+func (c checkCmd) FormatOutput(w io.Writer, q *Quota, req *Requirements, own *Requirements) error {
+	if isStructuredOutput(c.output) {
+		return writeReport(w, c.output, c.report(q, req, own))
+	}
 
 	line := func() error {
-		if _, err := fmt.Fprint(w, "+----------------+---------------+---------------+---------------+---------------+---------------+--------------+\n"); err != nil {
+		if _, err := fmt.Fprint(w, "+------------------+---------------+---------------+---------------+---------------+---------------+---------------+---------------+---------------+---------------+---------------+--------------+--------------+\n"); err != nil {
 			return err
 		}
 		return nil
 	}
-	if err := line(); err != nil {
-		return err
-	}
-	if _, err := fmt.Fprint(w, "|                | Static wrkld  | Jobs          | Sum           | Quota         | Status ststic |Status sum    |\n"); err != nil {
-		return err
+	row := func(r resourceRow) error {
+		rl := r.list(req)
+		static, ds, job, sum := totals(rl, r.resource)
+		ro := rollout(rl, r.resource)
+		st := q.Check(r, req, own)
+		if _, err := fmt.Fprintf(w, "|%-18.18s| %13v | %13v | %13v | %13v | %13v | %13s | %-13.13s | %13v | %13s | %13t |%13t |%13t |\n", r.title, &static, &ds, &job, &sum, &ro, printQuantity(st.Hard), st.Owner, &st.Used, printQuantity(st.Available), st.Static, st.Sum, st.Rollout); err != nil {
+			return err
+		}
+		return nil
 	}
 	if err := line(); err != nil {
 		return err
 	}
-	if _, err := fmt.Fprintf(w, "|CPU Limit       | %13v | %13v | %13v | %13v | %13t |%13t |\n", req.Limits.Cpu(), &jobCpuLim, &sumCpuLim, &qCpuLim, cpuLimOk, cpuSumLimOk); err != nil {
-		return err
-	}
-	if _, err := fmt.Fprintf(w, "|Memory Limit    | %13v | %13v | %13v | %13v | %13t |%13t |\n", req.Limits.Memory(), &jobMemLim, &sumMemLim, &qMemLim, memLimOk, memSumLimOk); err != nil {
+	if _, err := fmt.Fprint(w, "|                  | Static wrkld  | DaemonSets    | Jobs          | Sum           | During rollout| Quota         | Quota object  | Used          | Remaining     | Status ststic |Status sum    |Status rollout|\n"); err != nil {
 		return err
 	}
-	if _, err := fmt.Fprintf(w, "|CPU Request     | %13v | %13v | %13v | %13v | %13t |%13t |\n", req.Requests.Cpu(), &jobCpuReq, &sumCpuReq, &qCpuReq, cpuReqOk, cpuSumReqOk); err != nil {
-		return err
-	}
-	if _, err := fmt.Fprintf(w, "|Memory Request  | %13v | %13v | %13v | %13v | %13t |%13t |\n", req.Requests.Memory(), &jobMemReq, &sumMemReq, &qMemReq, memReqOk, memSumReqOk); err != nil {
+	if err := line(); err != nil {
 		return err
 	}
-	if _, err := fmt.Fprintf(w, "|Storage Request | %13v | %13v | %13v | %13v | %13t |%13t |\n", req.Requests.Storage(), &jobStrReq, &sumStrReq, &qStrReq, strReqOk, strSumReqOk); err != nil {
-		return err
+	for _, r := range resourceRows(req) {
+		if err := row(r); err != nil {
+			return err
+		}
 	}
 	if err := line(); err != nil {
 		return err
 	}
-	loglimit := func(r cv1.ResourceName) error {
-		cmc := req.Limits[r]
-		cmq := q.Status.Hard[r]
-		cmok := cmc.Cmp(cmq) < 0
-		if _, err := fmt.Fprintf(w, "|%15.15s |               |               | %13v | %13v |               |%13t |\n", r, &cmc, &cmq, cmok); err != nil {
+	loglimit := func(r resourceRow) error {
+		cmc := req.Limits[r.resource]
+		st := q.Check(r, req, own)
+		if _, err := fmt.Fprintf(w, "|%17.17s |               |               |               | %13v | %13v | %13s | %-13.13s | %13v | %13s |               |%13t |%13t |\n", r.title, &cmc, &cmc, printQuantity(st.Hard), st.Owner, &st.Used, printQuantity(st.Available), st.Sum, st.Rollout); err != nil {
 			return err
 		}
 		return nil
 	}
-	loglimit(cv1.ResourceConfigMaps)
-	loglimit(cv1.ResourceSecrets)
-	loglimit(cv1.ResourceServices)
-	loglimit(cv1.ResourcePersistentVolumeClaims)
+	for _, r := range countRows(req) {
+		loglimit(r)
+	}
 	if err := line(); err != nil {
 		return err
 	}
-	return nil
+	return printViolations(w, req)
+}
+
+// ownRequirements returns footprint of deployed release upgraded by the chart, already accounted in quota usage
+func (c checkCmd) ownRequirements(req *Requirements) (*Requirements, error) {
+	release := c.release
+	if c.remote && (release == "" || release == c.chart) {
+		return req, nil
+	}
+	if release == "" {
+		return &Requirements{ResourceRequirements: cv1.ResourceRequirements{Limits: cv1.ResourceList{}, Requests: cv1.ResourceList{}}}, nil
+	}
+	manifest, err := c.releaseManifest(release)
+	if err != nil {
+		return nil, err
+	}
+	return c.Parse(manifest)
+}
+
+// report builds machine readable requirements compared with quota
+func (c checkCmd) report(q *Quota, req *Requirements, own *Requirements) Report {
+	report := newReport("Check", c.scale, req)
+	for i, r := range append(resourceRows(req), countRows(req)...) {
+		report.Resources[i].setQuota(q.Check(r, req, own))
+	}
+	return report
 }
@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+
+	cv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+// CustomWorkload describes where custom resource keeps its pod template
+type CustomWorkload struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+
+	// PodTemplate is a path to PodTemplateSpec, e.g. spec.template
+	PodTemplate string `json:"podTemplate,omitempty"`
+	// PodSpec is a path to PodSpec, used when PodTemplate is not set
+	PodSpec string `json:"podSpec,omitempty"`
+	// Resources is a path to single container ResourceRequirements, used when neither PodTemplate nor PodSpec is set
+	Resources string `json:"resources,omitempty"`
+	// Replicas is a path to replica count, 1 is used if not set or missing
+	Replicas string `json:"replicas,omitempty"`
+	// Type is one of static (default), daemonset or job
+	Type string `json:"type,omitempty"`
+}
+
+// WorkloadConfig is content of --workload-config file
+type WorkloadConfig struct {
+	Workloads []CustomWorkload `json:"workloads"`
+}
+
+// loadWorkloads reads --workload-config unless already loaded, commands load it once before parsing manifests
+func (b *baseHelmCmd) loadWorkloads() error {
+	if b.workloads != nil {
+		return nil
+	}
+	wc, err := readWorkloadConfig(b.workloadConfig)
+	if err != nil {
+		return err
+	}
+	b.workloads = wc
+	return nil
+}
+
+func readWorkloadConfig(path string) (*WorkloadConfig, error) {
+	wc := WorkloadConfig{}
+	if path == "" {
+		return &wc, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.UnmarshalStrict(data, &wc); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for _, w := range wc.Workloads {
+		if w.Kind == "" {
+			return nil, fmt.Errorf("%s: workload kind is not defined", path)
+		}
+		if w.PodTemplate == "" && w.PodSpec == "" && w.Resources == "" {
+			return nil, fmt.Errorf("%s: no podTemplate, podSpec or resources path defined for %s", path, w.Kind)
+		}
+		switch w.Type {
+		case "", "static", "daemonset", "job":
+		default:
+			return nil, fmt.Errorf("%s: unknown workload type %s for %s", path, w.Type, w.Kind)
+		}
+	}
+	return &wc, nil
+}
+
+func fieldPath(path string) []string {
+	return strings.Split(strings.TrimPrefix(path, "."), ".")
+}
+
+// nestedObject converts field of unstructured object into typed one
+func nestedObject(obj map[string]interface{}, path string, tgt interface{}) (bool, error) {
+	v, found, err := unstructured.NestedFieldNoCopy(obj, fieldPath(path)...)
+	if err != nil || !found {
+		return found, err
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return false, err
+	}
+	return true, json.Unmarshal(data, tgt)
+}
+
+func (b baseHelmCmd) customParser(wc *WorkloadConfig) TypeParser {
+	return func(content []byte, cr *Requirements) (bool, error) {
+		obj := map[string]interface{}{}
+		if err := yaml.Unmarshal(content, &obj); err != nil {
+			return false, nil
+		}
+		u := unstructured.Unstructured{Object: obj}
+
+		pathid := fmt.Sprintf("%s: %s", u.GetKind(), u.GetName())
+		matched := false
+		missing := []string{}
+		for _, w := range wc.Workloads {
+			if w.Kind != u.GetKind() || (w.APIVersion != "" && w.APIVersion != u.GetAPIVersion()) {
+				continue
+			}
+
+			spec := cv1.PodSpec{}
+			var path string
+			var found bool
+			var err error
+			switch {
+			case w.PodTemplate != "":
+				path = w.PodTemplate
+				tmpl := cv1.PodTemplateSpec{}
+				found, err = nestedObject(obj, path, &tmpl)
+				spec = tmpl.Spec
+			case w.PodSpec != "":
+				path = w.PodSpec
+				found, err = nestedObject(obj, path, &spec)
+			default:
+				path = w.Resources
+				rr := cv1.ResourceRequirements{}
+				found, err = nestedObject(obj, path, &rr)
+				spec.Containers = []cv1.Container{{Name: strings.ToLower(u.GetKind()), Resources: rr}}
+			}
+			if err != nil {
+				return false, fmt.Errorf("%s: %w", pathid, err)
+			}
+			if !found {
+				missing = append(missing, path)
+				continue
+			}
+			matched = true
+
+			var replicas *int32
+			if w.Replicas != "" {
+				if r, ok, err := unstructured.NestedNumberAsFloat64(obj, fieldPath(w.Replicas)...); err != nil {
+					return false, fmt.Errorf("%s: %w", pathid, err)
+				} else if ok {
+					if r != math.Trunc(r) || r < 0 || r > math.MaxInt32 {
+						return false, fmt.Errorf("%s: replicas %v at %s is not a valid replica count", pathid, r, w.Replicas)
+					}
+					rv := int32(r)
+					replicas = &rv
+				}
+			}
+			repl := b.replicas(u.GetAPIVersion(), u.GetKind(), u.GetName(), replicas)
+
+			bucket := ""
+			switch w.Type {
+			case "daemonset":
+				bucket = dsPrefix
+				if repl, err = b.nodeCount(spec.NodeSelector); err != nil {
+					return false, err
+				}
+			case "job":
+				bucket = jobPrefix
+			}
+			if err := b.procPodSpec(bucket, u.GetKind(), u.GetName(), spec, cr, repl); err != nil {
+				return false, err
+			}
+		}
+		if !matched && len(missing) > 0 {
+			return false, fmt.Errorf("%s: none of configured paths %s found", pathid, strings.Join(missing, ", "))
+		}
+		return matched, nil
+	}
+}
@@ -0,0 +1,335 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	cv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+type diffCmd struct {
+	baseHelmCmd
+
+	oldValues     []string
+	oldValueFiles []string
+	oldRemote     bool
+	oldVersion    string
+	oldRepo       string
+	oldShowOnly   []string
+
+	output     string
+	thresholds map[string]string
+}
+
+// DiffReport is versioned output of requirements change between two chart inputs
+type DiffReport struct {
+	APIVersion string          `json:"apiVersion"`
+	Kind       string          `json:"kind"`
+	Resources  []ResourceDelta `json:"resources"`
+	Workloads  []WorkloadDelta `json:"workloads"`
+}
+
+// ResourceDelta is change of a resource sum, resource is named as in ResourceQuota
+type ResourceDelta struct {
+	Resource string            `json:"resource"`
+	Old      resource.Quantity `json:"old"`
+	New      resource.Quantity `json:"new"`
+	Delta    resource.Quantity `json:"delta"`
+}
+
+// WorkloadDelta is change of workload total requirements, workloads missing on one side have zero replicas there
+type WorkloadDelta struct {
+	Kind        string                   `json:"kind"`
+	Name        string                   `json:"name"`
+	Type        string                   `json:"type"`
+	OldReplicas int32                    `json:"oldReplicas"`
+	NewReplicas int32                    `json:"newReplicas"`
+	Delta       cv1.ResourceRequirements `json:"delta"`
+}
+
+func newDiffCommand() *cobra.Command {
+	diff := diffCmd{}
+
+	cmd := &cobra.Command{
+		Use:   "diff OLD [NEW]",
+		Short: "Show resource requirements change between two charts, releases or values",
+		Long:  rootCmdLongUsage,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 || len(args) > 2 {
+				return errors.New("requires one or two arguments: old and new chart path or release name")
+			}
+			return nil
+		},
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return validateOutput(diff.output, outputTable, outputJSON, outputYAML)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			return diff.run(args)
+		},
+	}
+	diff.propogateCmdFlags(cmd)
+	f := cmd.Flags()
+	f.StringArrayVar(&diff.oldValues, "old-set", []string{}, "set values of old chart on the command line (can specify multiple or separate values with commas: key1=val1,key2=val2)")
+	f.StringArrayVar(&diff.oldValueFiles, "old-values", []string{}, "specify values of old chart in a YAML file (can specify multiple)")
+	f.BoolVar(&diff.oldRemote, "old-remote", false, "Old is a deployed release instead of local chart")
+	f.StringVar(&diff.oldVersion, "old-version", "", "specify a version constraint for the old chart version to use")
+	f.StringVar(&diff.oldRepo, "old-repo", "", "chart repository url where to locate the old chart (--repo if not set)")
+	f.StringArrayVar(&diff.oldShowOnly, "old-show-only", []string{}, "only count manifests rendered from the given templates of the old chart (--show-only if not set)")
+	f.StringVar(&diff.output, "output", "", "Output format: table (default), json or yaml")
+	f.StringToStringVar(&diff.thresholds, "threshold", map[string]string{}, "Fail when resource sum grows more than threshold, e.g. requests.cpu=1,requests.memory=2Gi")
+	return cmd
+}
+
+// run compares OLD with NEW, NEW is the same chart as OLD when not given so only values differ
+func (d diffCmd) run(args []string) error {
+	if err := d.loadWorkloads(); err != nil {
+		return err
+	}
+	oldCmd, newCmd := d.sides(args)
+	oldReq, err := oldCmd.GetRequirements()
+	if err != nil {
+		return err
+	}
+	newReq, err := newCmd.GetRequirements()
+	if err != nil {
+		return err
+	}
+	report := d.report(oldReq, newReq)
+	thresholds, err := d.parseThresholds(report)
+	if err != nil {
+		return err
+	}
+	if err := d.FormatOutput(os.Stdout, report); err != nil {
+		return err
+	}
+	return exceeded(report, thresholds)
+}
+
+// sides returns inputs of OLD and NEW. Values and version are set per side, repository and show-only filter
+// default to the new side ones, other template flags apply to both.
+func (d diffCmd) sides(args []string) (baseHelmCmd, baseHelmCmd) {
+	oldCmd := d.baseHelmCmd
+	oldCmd.chart = args[0]
+	oldCmd.values = d.oldValues
+	oldCmd.valueFiles = d.oldValueFiles
+	oldCmd.stringValues, oldCmd.fileValues, oldCmd.jsonValues = nil, nil, nil
+	oldCmd.manifests = nil
+	oldCmd.remote = d.oldRemote
+	oldCmd.version = d.oldVersion
+	if d.oldRepo != "" {
+		oldCmd.repo = d.oldRepo
+	}
+	if len(d.oldShowOnly) > 0 {
+		oldCmd.showOnly = d.oldShowOnly
+	}
+	newCmd := d.baseHelmCmd
+	newCmd.chart = args[len(args)-1]
+	return oldCmd, newCmd
+}
+
+// diffRows returns rows of both requirements, count rows are marked by limit flag as in check
+func diffRows(oldReq *Requirements, newReq *Requirements) ([]resourceRow, []resourceRow) {
+	unique := func(rows []resourceRow) []resourceRow {
+		res := []resourceRow{}
+		seen := map[cv1.ResourceName]bool{}
+		for _, r := range rows {
+			if !seen[r.quota] {
+				seen[r.quota] = true
+				res = append(res, r)
+			}
+		}
+		return res
+	}
+	return unique(append(resourceRows(oldReq), resourceRows(newReq)...)), unique(append(countRows(oldReq), countRows(newReq)...))
+}
+
+// delta returns new value minus old one
+func delta(oldValue, newValue resource.Quantity) resource.Quantity {
+	d := newValue.DeepCopy()
+	d.Sub(oldValue)
+	return d
+}
+
+func (d diffCmd) report(oldReq *Requirements, newReq *Requirements) DiffReport {
+	report := DiffReport{
+		APIVersion: reportAPIVersion,
+		Kind:       "Diff",
+		Resources:  []ResourceDelta{},
+		Workloads:  []WorkloadDelta{},
+	}
+	rows, counts := diffRows(oldReq, newReq)
+	for _, r := range rows {
+		_, _, _, o := totals(r.list(oldReq), r.resource)
+		_, _, _, n := totals(r.list(newReq), r.resource)
+		report.Resources = append(report.Resources, ResourceDelta{string(r.quota), o, n, delta(o, n)})
+	}
+	for _, r := range counts {
+		o, n := oldReq.Limits[r.resource], newReq.Limits[r.resource]
+		report.Resources = append(report.Resources, ResourceDelta{string(r.quota), o, n, delta(o, n)})
+	}
+
+	workloads := map[string]*WorkloadDelta{}
+	keys := []string{}
+	add := func(req *Requirements, isNew bool) {
+		for _, w := range (breakdownCmd{}).report(req).Workloads {
+			key := fmt.Sprintf("%s/%s/%s", w.Kind, w.Name, w.Type)
+			wd, ok := workloads[key]
+			if !ok {
+				wd = &WorkloadDelta{
+					Kind: w.Kind,
+					Name: w.Name,
+					Type: w.Type,
+					Delta: cv1.ResourceRequirements{
+						Limits:   cv1.ResourceList{},
+						Requests: cv1.ResourceList{},
+					},
+				}
+				workloads[key] = wd
+				keys = append(keys, key)
+			}
+			sign := int32(-1)
+			if isNew {
+				sign = 1
+				wd.NewReplicas += w.Replicas
+			} else {
+				wd.OldReplicas += w.Replicas
+			}
+			addPod("", w.PerPod, &wd.Delta, sign*w.Replicas)
+		}
+	}
+	add(oldReq, false)
+	add(newReq, true)
+	sort.Strings(keys)
+	for _, key := range keys {
+		wd := workloads[key]
+		changed := wd.OldReplicas != wd.NewReplicas
+		for _, rl := range []cv1.ResourceList{wd.Delta.Limits, wd.Delta.Requests} {
+			for _, v := range rl {
+				changed = changed || !v.IsZero()
+			}
+		}
+		if changed {
+			report.Workloads = append(report.Workloads, *wd)
+		}
+	}
+	return report
+}
+
+// parseThresholds returns thresholds indexed by resource named as in ResourceQuota. Names of resources neither input
+// requires are rejected, except extended resources and storage classes, so a typo does not disable the check.
+func (d diffCmd) parseThresholds(report DiffReport) (map[string]resource.Quantity, error) {
+	known := map[string]bool{}
+	for _, r := range report.Resources {
+		known[r.Resource] = true
+	}
+	res := map[string]resource.Quantity{}
+	for name, value := range d.thresholds {
+		r := quotaResource(cv1.ResourceName(name)).String()
+		if !known[r] && !isExtendedResource(cv1.ResourceName(strings.TrimPrefix(r, cv1.DefaultResourceRequestsPrefix))) && !strings.Contains(r, storageClassSuffix) {
+			return nil, fmt.Errorf("unknown threshold resource %s, use resource names as in ResourceQuota, e.g. requests.cpu or limits.memory", name)
+		}
+		threshold, err := resource.ParseQuantity(value)
+		if err != nil {
+			return nil, fmt.Errorf("threshold %s: %w", name, err)
+		}
+		res[r] = threshold
+	}
+	return res, nil
+}
+
+// exceeded returns error when resource sum grows more than its threshold
+func exceeded(report DiffReport, thresholds map[string]resource.Quantity) error {
+	for _, r := range report.Resources {
+		if threshold, ok := thresholds[r.Resource]; ok && r.Delta.Cmp(threshold) > 0 {
+			return Error{fmt.Errorf("%s grows by %v exceeding threshold %v", r.Resource, &r.Delta, &threshold), ExitThresholdExceeded}
+		}
+	}
+	return nil
+}
+
+// printDelta returns signed delta
+func printDelta(v resource.Quantity) string {
+	if v.Sign() > 0 {
+		return "+" + v.String()
+	}
+	return v.String()
+}
+
+func (d diffCmd) FormatOutput(w io.Writer, report DiffReport) error {
+	if isStructuredOutput(d.output) {
+		return writeReport(w, d.output, report)
+	}
+
+	titles := map[string]string{}
+	for _, r := range append(append(append([]resourceRow{}, computeRows...), storageRow), countRows(&Requirements{})...) {
+		titles[string(r.quota)] = r.title
+	}
+	line := func() error {
+		if _, err := fmt.Fprint(w, "+------------------+---------------+---------------+---------------+\n"); err != nil {
+			return err
+		}
+		return nil
+	}
+	if err := line(); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprint(w, "|                  | Old           | New           | Delta         |\n"); err != nil {
+		return err
+	}
+	if err := line(); err != nil {
+		return err
+	}
+	for _, r := range report.Resources {
+		title, ok := titles[r.Resource]
+		if !ok {
+			title = r.Resource
+		}
+		if _, err := fmt.Fprintf(w, "|%-18.18s| %13v | %13v | %13s |\n", title, &r.Old, &r.New, printDelta(r.Delta)); err != nil {
+			return err
+		}
+	}
+	if err := line(); err != nil {
+		return err
+	}
+	if len(report.Workloads) == 0 {
+		return nil
+	}
+
+	wline := func() error {
+		if _, err := fmt.Fprint(w, "+------------------------------+-----------+------------+"+strings.Repeat("---------------+", len(computeRows))+"\n"); err != nil {
+			return err
+		}
+		return nil
+	}
+	if err := wline(); err != nil {
+		return err
+	}
+	header := strings.Builder{}
+	for _, r := range computeRows {
+		fmt.Fprintf(&header, " %-13.13s |", r.title)
+	}
+	if _, err := fmt.Fprintf(w, "| Workload                     | Type      | Replicas   |%s\n", header.String()); err != nil {
+		return err
+	}
+	if err := wline(); err != nil {
+		return err
+	}
+	for _, wd := range report.Workloads {
+		values := strings.Builder{}
+		for _, r := range computeRows {
+			fmt.Fprintf(&values, " %13s |", printDelta(r.list(&Requirements{ResourceRequirements: wd.Delta})[r.resource]))
+		}
+		replicas := fmt.Sprintf("%d -> %d", wd.OldReplicas, wd.NewReplicas)
+		if _, err := fmt.Fprintf(w, "|%-30.30s| %-9.9s | %10s |%s\n", wd.Kind+"/"+wd.Name, wd.Type, replicas, values.String()); err != nil {
+			return err
+		}
+	}
+	return wline()
+}
@@ -1,7 +1,32 @@
 package cmd
 
+import "fmt"
+
+// Exit codes of check and diff
+const (
+	// ExitNotFit is returned when requirements without jobs exceed quota or violate LimitRange
+	ExitNotFit = 2
+	// ExitNotFitJobs is returned when requirements fit quota only while jobs are not running
+	ExitNotFitJobs = 3
+	// ExitNotEvaluated is returned when quota or requirements could not be evaluated
+	ExitNotEvaluated = 4
+	// ExitThresholdExceeded is returned by diff when resource grows more than its threshold
+	ExitThresholdExceeded = 5
+	// ExitNotFitRollout is returned when requirements fit quota but pods surged by rolling update do not
+	ExitNotFitRollout = 6
+)
+
 // Error to report errors
 type Error struct {
 	error
 	Code int
 }
+
+func (e Error) Unwrap() error {
+	return e.error
+}
+
+// notEvaluated marks error preventing check from evaluating requirements against quota
+func notEvaluated(err error) error {
+	return Error{fmt.Errorf("could not evaluate: %w", err), ExitNotEvaluated}
+}
@@ -1,7 +1,9 @@
 package cmd
 
 import (
+	"bufio"
 	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
@@ -10,20 +12,42 @@ import (
 
 var yamlSeparator = []byte("\n---\n")
 
-func getTemplate(template, namespace string, variables []string, values []string) ([]byte, error) {
-	args := []string{"template", template}
-	if namespace != "" {
-		args = append(args, "--namespace", namespace)
+// getTemplate renders chart by helm template passing through template flags
+func (b baseHelmCmd) getTemplate() ([]byte, error) {
+	args := []string{"template"}
+	if b.releaseName != "" {
+		args = append(args, b.releaseName)
+	}
+	args = append(args, b.chart)
+	if b.namespace != "" {
+		args = append(args, "--namespace", b.namespace)
 	}
-	for _, v := range variables {
-		args = append(args, "--set", v)
+	flags := []struct {
+		name   string
+		values []string
+	}{
+		{"--set", b.values},
+		{"--values", b.valueFiles},
+		{"--set-string", b.stringValues},
+		{"--set-file", b.fileValues},
+		{"--set-json", b.jsonValues},
+		{"--version", []string{b.version}},
+		{"--repo", []string{b.repo}},
+		{"--api-versions", b.apiVersions},
+		{"--kube-version", []string{b.kubeVersion}},
+		{"--show-only", b.showOnly},
 	}
-	for _, v := range values {
-		args = append(args, "--values", v)
+	for _, f := range flags {
+		for _, v := range f.values {
+			if v != "" {
+				args = append(args, f.name, v)
+			}
+		}
 	}
-	// fmt.Printf("Running: %s %v\n", os.Getenv("HELM_BIN"), args)
-	cmd := exec.Command(os.Getenv("HELM_BIN"), args...)
-	return outputWithRichError(cmd)
+	if b.includeCRDs {
+		args = append(args, "--include-crds")
+	}
+	return helm(args...)
 }
 
 func getRelease(release, namespace string) ([]byte, error) {
@@ -31,8 +55,40 @@ func getRelease(release, namespace string) ([]byte, error) {
 	if namespace != "" {
 		args = append(args, "--namespace", namespace)
 	}
-	cmd := exec.Command(os.Getenv("HELM_BIN"), args...)
-	return outputWithRichError(cmd)
+	return helm(args...)
+}
+
+// execReleases lists releases by helm list and fetches their manifests
+func execReleases(namespace string, all bool) ([]deployedRelease, error) {
+	args := []string{"list", "--output", "json", "--max", "0"}
+	if all {
+		args = append(args, "--all-namespaces")
+	} else if namespace != "" {
+		args = append(args, "--namespace", namespace)
+	}
+	out, err := helm(args...)
+	if err != nil {
+		return nil, err
+	}
+	res := []deployedRelease{}
+	if err := json.Unmarshal(out, &res); err != nil {
+		return nil, err
+	}
+	for i, rel := range res {
+		if res[i].Manifest, err = getRelease(rel.Name, rel.Namespace); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// helm runs helm binary the plugin is started by, targeting the same cluster as SDK
+func helm(args ...string) ([]byte, error) {
+	bin := os.Getenv("HELM_BIN")
+	if bin == "" {
+		return nil, errors.New("HELM_BIN is not set, helm binary can be used only when running as helm plugin")
+	}
+	return outputWithRichError(exec.Command(bin, append(args, kube.helmArgs()...)...))
 }
 
 func outputWithRichError(cmd *exec.Cmd) ([]byte, error) {
@@ -44,6 +100,22 @@ func outputWithRichError(cmd *exec.Cmd) ([]byte, error) {
 	return output, err
 }
 
+// splitManifest splits multi-document yaml into documents
+func splitManifest(manifest []byte) ([][]byte, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(manifest))
+	scanner.Split(scanYamlSpecs)
+	scanner.Buffer(make([]byte, bufio.MaxScanTokenSize), 10485760)
+
+	docs := [][]byte{}
+	for scanner.Scan() {
+		docs = append(docs, bytes.Clone(scanner.Bytes()))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
+
 func scanYamlSpecs(data []byte, atEOF bool) (int, []byte, error) {
 	if atEOF && len(data) == 0 {
 		return 0, nil, nil
@@ -1,16 +1,25 @@
 package cmd
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	appsv1 "k8s.io/api/apps/v1"
 	cv1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"sigs.k8s.io/yaml"
 )
 
@@ -112,6 +121,16 @@ func TestParseDefaults_Deployment(t *testing.T) {
 			require: true,
 		},
 	}
+	cr, err := s.Parse(dbytes)
+	require.NoError(t, err)
+	// requests omitted next to limits default to them as in API server
+	assert.True(t, cr.Requests.Cpu().Equal(resource.MustParse("500m")), cr.Requests.Cpu())
+	assert.True(t, cr.Requests.Memory().Equal(resource.MustParse("1600Mi")), cr.Requests.Memory())
+
+	c := &depl.Spec.Template.Spec.Containers[0]
+	c.Resources = cv1.ResourceRequirements{Requests: c.Resources.Limits}
+	dbytes, err = yaml.Marshal(depl)
+	require.NoError(t, err)
 	_, err = s.Parse(dbytes)
 	require.Error(t, err)
 }
@@ -156,3 +175,1094 @@ func TestParseDefaults2_Deployment(t *testing.T) {
 	assert.True(t, cr.Requests.Cpu().Equal(resource.MustParse("2")), cr.Requests.Cpu())
 	assert.True(t, cr.Requests.Memory().Equal(resource.MustParse("2Gi")), cr.Requests.Memory())
 }
+
+func TestParse_DaemonSet(t *testing.T) {
+	f, err := os.OpenFile("../testdata/ds.yaml", os.O_RDONLY, 0644)
+	require.NoError(t, err)
+	assert.NotNil(t, f)
+	defer f.Close()
+	date, err := io.ReadAll(f)
+	require.NoError(t, err)
+	s := sumCmd{
+		baseHelmCmd: baseHelmCmd{
+			nodes: 3,
+		},
+	}
+	cr, err := s.Parse(date)
+	require.NoError(t, err)
+	assert.True(t, cr.Limits.Cpu().IsZero(), cr.Limits.Cpu())
+	assert.True(t, cr.Limits[dsCpu].Equal(resource.MustParse("300m")), cr.Limits[dsCpu])
+	assert.True(t, cr.Limits[dsMemory].Equal(resource.MustParse("600Mi")), cr.Limits[dsMemory])
+	assert.True(t, cr.Requests[dsCpu].Equal(resource.MustParse("150m")), cr.Requests[dsCpu])
+	assert.True(t, cr.Requests[dsMemory].Equal(resource.MustParse("300Mi")), cr.Requests[dsMemory])
+}
+
+func TestParse_InitContainers(t *testing.T) {
+	f, err := os.OpenFile("../testdata/init.yaml", os.O_RDONLY, 0644)
+	require.NoError(t, err)
+	assert.NotNil(t, f)
+	defer f.Close()
+	date, err := io.ReadAll(f)
+	require.NoError(t, err)
+	s := sumCmd{}
+	cr, err := s.Parse(date)
+	require.NoError(t, err)
+	assert.True(t, cr.Limits.Cpu().Equal(resource.MustParse("2200m")), cr.Limits.Cpu())
+	assert.True(t, cr.Limits.Memory().Equal(resource.MustParse("1200Mi")), cr.Limits.Memory())
+	assert.True(t, cr.Requests.Cpu().Equal(resource.MustParse("1200m")), cr.Requests.Cpu())
+	assert.True(t, cr.Requests.Memory().Equal(resource.MustParse("600Mi")), cr.Requests.Memory())
+}
+
+func TestParse_Workloads(t *testing.T) {
+	f, err := os.OpenFile("../testdata/workloads.yaml", os.O_RDONLY, 0644)
+	require.NoError(t, err)
+	assert.NotNil(t, f)
+	defer f.Close()
+	date, err := io.ReadAll(f)
+	require.NoError(t, err)
+	s := sumCmd{}
+	cr, err := s.Parse(date)
+	require.NoError(t, err)
+	assert.True(t, cr.Limits.Cpu().Equal(resource.MustParse("800m")), cr.Limits.Cpu())
+	assert.True(t, cr.Limits.Memory().Equal(resource.MustParse("800Mi")), cr.Limits.Memory())
+	assert.True(t, cr.Requests.Cpu().Equal(resource.MustParse("600m")), cr.Requests.Cpu())
+	assert.True(t, cr.Requests.Memory().Equal(resource.MustParse("600Mi")), cr.Requests.Memory())
+	assert.True(t, cr.Limits[jobCpu].Equal(resource.MustParse("300m")), cr.Limits[jobCpu])
+	assert.True(t, cr.Requests[jobMemory].Equal(resource.MustParse("150Mi")), cr.Requests[jobMemory])
+}
+
+func TestParse_JobParallelism(t *testing.T) {
+	f, err := os.OpenFile("../testdata/cj2.yaml", os.O_RDONLY, 0644)
+	require.NoError(t, err)
+	assert.NotNil(t, f)
+	defer f.Close()
+	date, err := io.ReadAll(f)
+	require.NoError(t, err)
+	s := sumCmd{
+		baseHelmCmd: baseHelmCmd{
+			concurrentJobs: 2,
+		},
+	}
+	cr, err := s.Parse(date)
+	require.NoError(t, err)
+	assert.True(t, cr.Limits[jobCpu].Equal(resource.MustParse("800m")), cr.Limits[jobCpu])
+	assert.True(t, cr.Limits[jobMemory].Equal(resource.MustParse("800Mi")), cr.Limits[jobMemory])
+	assert.True(t, cr.Requests[jobCpu].Equal(resource.MustParse("400m")), cr.Requests[jobCpu])
+	assert.True(t, cr.Requests[jobMemory].Equal(resource.MustParse("400Mi")), cr.Requests[jobMemory])
+}
+
+func TestParse_CustomWorkloads(t *testing.T) {
+	f, err := os.OpenFile("../testdata/crd.yaml", os.O_RDONLY, 0644)
+	require.NoError(t, err)
+	assert.NotNil(t, f)
+	defer f.Close()
+	date, err := io.ReadAll(f)
+	require.NoError(t, err)
+	s := sumCmd{
+		baseHelmCmd: baseHelmCmd{
+			workloadConfig: "../testdata/workload-config.yaml",
+		},
+	}
+	cr, err := s.Parse(date)
+	require.NoError(t, err)
+	assert.True(t, cr.Limits.Cpu().Equal(resource.MustParse("3800m")), cr.Limits.Cpu())
+	assert.True(t, cr.Limits.Memory().Equal(resource.MustParse("7880Mi")), cr.Limits.Memory())
+	assert.True(t, cr.Requests.Cpu().Equal(resource.MustParse("1900m")), cr.Requests.Cpu())
+	assert.True(t, cr.Requests.Memory().Equal(resource.MustParse("7780Mi")), cr.Requests.Memory())
+	assert.True(t, cr.Limits[jobCpu].Equal(resource.MustParse("400m")), cr.Limits[jobCpu])
+	assert.True(t, cr.Requests[jobMemory].Equal(resource.MustParse("400Mi")), cr.Requests[jobMemory])
+}
+
+func TestParse_CustomWorkloadsInvalid(t *testing.T) {
+	config := filepath.Join(t.TempDir(), "workloads.yaml")
+	require.NoError(t, os.WriteFile(config, []byte(`workloads:
+  - kind: Rollout
+    podTemplate: spec.podTemplate
+    replicas: spec.replicas
+`), 0644))
+	s := sumCmd{baseHelmCmd: baseHelmCmd{workloadConfig: config}}
+	_, err := s.Parse([]byte(`apiVersion: argoproj.io/v1alpha1
+kind: Rollout
+metadata:
+  name: rollout
+spec:
+  template: {}
+`))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Rollout: rollout")
+	assert.Contains(t, err.Error(), "spec.podTemplate")
+
+	_, err = s.Parse([]byte(`apiVersion: argoproj.io/v1alpha1
+kind: Rollout
+metadata:
+  name: rollout
+spec:
+  replicas: 1.5
+  podTemplate: {}
+`))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "1.5")
+}
+
+func TestLoadWorkloads(t *testing.T) {
+	config := filepath.Join(t.TempDir(), "workloads.yaml")
+	require.NoError(t, os.WriteFile(config, []byte(`workloads:
+  - kind: Rollout
+    podTemplate: spec.template
+`), 0644))
+	manifest := []byte(`apiVersion: argoproj.io/v1alpha1
+kind: Rollout
+metadata:
+  name: rollout
+spec:
+  template:
+    spec:
+      containers:
+        - name: app
+          resources:
+            limits:
+              cpu: 100m
+`)
+	loaded := sumCmd{baseHelmCmd: baseHelmCmd{workloadConfig: config}}
+	require.NoError(t, loaded.loadWorkloads())
+
+	require.NoError(t, os.WriteFile(config, []byte("workloads: []\n"), 0644))
+	cr, err := loaded.Parse(manifest)
+	require.NoError(t, err)
+	assert.True(t, cr.Limits.Cpu().Equal(resource.MustParse("100m")), cr.Limits.Cpu())
+
+	s := sumCmd{baseHelmCmd: baseHelmCmd{workloadConfig: config}}
+	cr, err = s.Parse(manifest)
+	require.NoError(t, err)
+	assert.True(t, cr.Limits.Cpu().IsZero(), cr.Limits.Cpu())
+}
+
+func TestParse_EphemeralStorage(t *testing.T) {
+	depl := appsv1.Deployment{
+		TypeMeta: metav1.TypeMeta{
+			Kind: "Deployment",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+		},
+		Spec: appsv1.DeploymentSpec{
+			Template: cv1.PodTemplateSpec{
+				Spec: cv1.PodSpec{
+					Containers: []cv1.Container{
+						{
+							Name: "test-container",
+							Resources: cv1.ResourceRequirements{
+								Limits: cv1.ResourceList{
+									cv1.ResourceEphemeralStorage: resource.MustParse("2Gi"),
+								},
+							},
+						},
+						{
+							Name: "test-container2",
+						},
+					},
+				},
+			},
+		},
+	}
+
+	dbytes, err := yaml.Marshal(depl)
+	require.NoError(t, err)
+	s := sumCmd{
+		baseHelmCmd: baseHelmCmd{
+			requireEphemeral: true,
+		},
+	}
+	_, err = s.Parse(dbytes)
+	require.Error(t, err)
+
+	s.defaultEphemeralLimit = "1Gi"
+	s.defaultEphemeralReq = "500Mi"
+	cr, err := s.Parse(dbytes)
+	require.NoError(t, err)
+	assert.True(t, cr.Limits.StorageEphemeral().Equal(resource.MustParse("3Gi")), cr.Limits.StorageEphemeral())
+	assert.True(t, cr.Requests.StorageEphemeral().Equal(resource.MustParse("2548Mi")), cr.Requests.StorageEphemeral())
+}
+
+func TestParse_ExtendedResources(t *testing.T) {
+	f, err := os.OpenFile("../testdata/gpu.yaml", os.O_RDONLY, 0644)
+	require.NoError(t, err)
+	assert.NotNil(t, f)
+	defer f.Close()
+	date, err := io.ReadAll(f)
+	require.NoError(t, err)
+	s := sumCmd{}
+	cr, err := s.Parse(date)
+	require.NoError(t, err)
+	gpu := cv1.ResourceName("nvidia.com/gpu")
+	assert.True(t, cr.Requests.Name(gpu, resource.DecimalSI).Equal(resource.MustParse("2")), cr.Requests.Name(gpu, resource.DecimalSI))
+	assert.True(t, cr.Requests.Name(jobPrefix+gpu, resource.DecimalSI).Equal(resource.MustParse("4")), cr.Requests.Name(jobPrefix+gpu, resource.DecimalSI))
+	assert.True(t, cr.Requests.Name("hugepages-2Mi", resource.BinarySI).Equal(resource.MustParse("200Mi")), cr.Requests.Name("hugepages-2Mi", resource.BinarySI))
+
+	rows := extendedRows(cr)
+	require.Len(t, rows, 2)
+	assert.Equal(t, cv1.ResourceName("requests.hugepages-2Mi"), rows[0].quota)
+	assert.Equal(t, cv1.ResourceName("requests.nvidia.com/gpu"), rows[1].quota)
+}
+
+func TestParse_Autoscaler(t *testing.T) {
+	f, err := os.OpenFile("../testdata/hpa.yaml", os.O_RDONLY, 0644)
+	require.NoError(t, err)
+	assert.NotNil(t, f)
+	defer f.Close()
+	date, err := io.ReadAll(f)
+	require.NoError(t, err)
+
+	for scale, cpu := range map[string]string{scaleMin: "200m", scaleCurrent: "200m", scaleMax: "1"} {
+		s := sumCmd{
+			baseHelmCmd: baseHelmCmd{
+				scale: scale,
+			},
+		}
+		cr, err := s.Parse(date)
+		require.NoError(t, err)
+		assert.True(t, cr.Limits.Cpu().Equal(resource.MustParse(cpu)), scale, cr.Limits.Cpu())
+	}
+
+	s := sumCmd{baseHelmCmd: baseHelmCmd{scale: scaleMax}}
+	cr, err := s.Parse(bytes.Replace(date, []byte("    apiVersion: apps/v1"), []byte("    apiVersion: example.com/v1"), 1))
+	require.NoError(t, err)
+	assert.True(t, cr.Limits.Cpu().Equal(resource.MustParse("100m")), "autoscaler of other API group", cr.Limits.Cpu())
+
+	s.scale = scaleAll
+	_, err = s.Parse(date)
+	assert.ErrorContains(t, err, "only by sum")
+}
+
+func TestParse_RolloutSurge(t *testing.T) {
+	f, err := os.OpenFile("../testdata/surge.yaml", os.O_RDONLY, 0644)
+	require.NoError(t, err)
+	assert.NotNil(t, f)
+	defer f.Close()
+	date, err := io.ReadAll(f)
+	require.NoError(t, err)
+	s := sumCmd{}
+	cr, err := s.Parse(date)
+	require.NoError(t, err)
+	assert.True(t, cr.Limits.Cpu().Equal(resource.MustParse("1400m")), cr.Limits.Cpu())
+	ro := rollout(cr.Limits, cv1.ResourceCPU)
+	assert.True(t, ro.Equal(resource.MustParse("1800m")), ro)
+}
+
+func TestParse_VolumeClaimTemplates(t *testing.T) {
+	f, err := os.OpenFile("../testdata/ss2.yaml", os.O_RDONLY, 0644)
+	require.NoError(t, err)
+	assert.NotNil(t, f)
+	defer f.Close()
+	date, err := io.ReadAll(f)
+	require.NoError(t, err)
+	s := sumCmd{}
+	cr, err := s.Parse(date)
+	require.NoError(t, err)
+	assert.True(t, cr.Requests.Storage().Equal(resource.MustParse("38Gi")), cr.Requests.Storage())
+	pvc := cr.Limits[cv1.ResourcePersistentVolumeClaims]
+	assert.Equal(t, int64(7), pvc.Value())
+}
+
+func TestParse_StorageClasses(t *testing.T) {
+	f, err := os.OpenFile("../testdata/sc.yaml", os.O_RDONLY, 0644)
+	require.NoError(t, err)
+	assert.NotNil(t, f)
+	defer f.Close()
+	date, err := io.ReadAll(f)
+	require.NoError(t, err)
+	s := sumCmd{
+		baseHelmCmd: baseHelmCmd{
+			defaultStorageClass: "standard",
+		},
+	}
+	cr, err := s.Parse(date)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"fast", "standard"}, storageClasses(cr))
+	assert.True(t, cr.Requests.Storage().Equal(resource.MustParse("26Gi")), cr.Requests.Storage())
+	assert.True(t, cr.Requests.Name(scPrefix+"fast", resource.BinarySI).Equal(resource.MustParse("20Gi")))
+	assert.True(t, cr.Requests.Name(scPrefix+"standard", resource.BinarySI).Equal(resource.MustParse("5Gi")))
+	assert.Equal(t, int64(2), cr.Limits.Name(scPrefix+"fast", resource.DecimalSI).Value())
+	assert.Equal(t, cv1.ResourceName("fast.storageclass.storage.k8s.io/requests.storage"), storageClassQuota("fast", cv1.ResourceRequestsStorage))
+}
+
+func TestParse_DiscoverStorageClass(t *testing.T) {
+	lookups := 0
+	s := sumCmd{
+		baseHelmCmd: baseHelmCmd{
+			storageClass: func() string {
+				lookups++
+				return "standard"
+			},
+		},
+	}
+	_, err := s.Parse([]byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm\n"))
+	require.NoError(t, err)
+	assert.Equal(t, 0, lookups, "no claims, no lookup")
+
+	date, err := os.ReadFile("../testdata/sc.yaml")
+	require.NoError(t, err)
+	cr, err := s.Parse(date)
+	require.NoError(t, err)
+	assert.Equal(t, 1, lookups, "only claim without storage class triggers lookup")
+	assert.Equal(t, []string{"fast", "standard"}, storageClasses(cr))
+
+	saved := kube
+	defer func() { kube = saved }()
+	kube = kubeOptions{kubeconfig: filepath.Join(t.TempDir(), "missing")}
+	assert.Equal(t, "", discoverDefaultStorageClass()(), "lookup errors mean no default class")
+}
+
+func TestParse_LimitRange(t *testing.T) {
+	f, err := os.OpenFile("../testdata/limitrange.yaml", os.O_RDONLY, 0644)
+	require.NoError(t, err)
+	assert.NotNil(t, f)
+	defer f.Close()
+	date, err := io.ReadAll(f)
+	require.NoError(t, err)
+	s := sumCmd{}
+	cr, err := s.Parse(date)
+	require.NoError(t, err)
+	assert.True(t, cr.Limits.Cpu().Equal(resource.MustParse("5")), cr.Limits.Cpu())
+	assert.True(t, cr.Limits.Memory().Equal(resource.MustParse("1280Mi")), cr.Limits.Memory())
+	assert.True(t, cr.Requests.Cpu().Equal(resource.MustParse("2400m")), cr.Requests.Cpu())
+	assert.True(t, cr.Requests.Memory().Equal(resource.MustParse("1280Mi")), cr.Requests.Memory())
+	assert.Equal(t, []string{
+		"Deployment: web, Container: app: cpu limit to request ratio 5 is above maxLimitRequestRatio 4 of LimitRange limits",
+		"Pod: big, Container: c: cpu limit 2 is above max 1 of LimitRange limits",
+		"Pod: big, Container: c: cpu limit to request ratio 10 is above maxLimitRequestRatio 4 of LimitRange limits",
+		"PVC: data: storage request 500Mi is below min 1Gi of LimitRange limits",
+	}, cr.Violations)
+}
+
+// requests returns requirements of statically running pods requesting list
+func requests(rl cv1.ResourceList) *Requirements {
+	return &Requirements{ResourceRequirements: cv1.ResourceRequirements{Limits: rl, Requests: rl}}
+}
+
+func TestMergeQuotas(t *testing.T) {
+	q := MergeQuotas([]cv1.ResourceQuota{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "compute"},
+			Status: cv1.ResourceQuotaStatus{
+				Hard: cv1.ResourceList{
+					cv1.ResourceLimitsCPU: resource.MustParse("8"),
+					cv1.ResourceCPU:       resource.MustParse("4"),
+				},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "storage"},
+			Status: cv1.ResourceQuotaStatus{
+				Hard: cv1.ResourceList{
+					cv1.ResourceRequestsCPU:     resource.MustParse("6"),
+					cv1.ResourceRequestsStorage: resource.MustParse("50Gi"),
+				},
+			},
+		},
+	})
+	req := requests(cv1.ResourceList{
+		cv1.ResourceCPU:     resource.MustParse("5"),
+		cv1.ResourceMemory:  resource.MustParse("5Gi"),
+		cv1.ResourceStorage: resource.MustParse("10Gi"),
+	})
+	own := requests(cv1.ResourceList{})
+
+	st := q.Check(computeRows[0], req, own)
+	assert.Equal(t, "compute", st.Owner)
+	assert.Equal(t, "8", printQuantity(st.Hard))
+	assert.True(t, st.Sum)
+
+	// the smallest of merged limits applies
+	st = q.Check(computeRows[3], req, own)
+	assert.Equal(t, "compute", st.Owner)
+	assert.Equal(t, "4", printQuantity(st.Hard))
+	assert.False(t, st.Sum)
+
+	st = q.Check(storageRow, req, own)
+	assert.Equal(t, "storage", st.Owner)
+	assert.True(t, st.Sum)
+
+	st = q.Check(computeRows[4], req, own)
+	assert.Equal(t, "none", printQuantity(st.Hard))
+	assert.Equal(t, "none", printQuantity(st.Available))
+	assert.Equal(t, "", st.Owner)
+	assert.True(t, st.Sum)
+}
+
+func TestQuotaUsage(t *testing.T) {
+	q := MergeQuotas([]cv1.ResourceQuota{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "compute"},
+			Status: cv1.ResourceQuotaStatus{
+				Hard: cv1.ResourceList{
+					cv1.ResourceRequestsCPU: resource.MustParse("4"),
+				},
+				Used: cv1.ResourceList{
+					cv1.ResourceRequestsCPU: resource.MustParse("3"),
+				},
+			},
+		},
+	})
+	row := computeRows[3]
+	cpu := func(v string) *Requirements {
+		return requests(cv1.ResourceList{cv1.ResourceCPU: resource.MustParse(v)})
+	}
+
+	st := q.Check(row, cpu("2"), cpu("0"))
+	assert.Equal(t, "3", st.Used.String())
+	assert.Equal(t, "1", printQuantity(st.Available))
+	assert.False(t, st.Sum)
+	assert.True(t, q.Check(row, cpu("1"), cpu("0")).Sum)
+
+	// release already running 2 CPU is upgraded
+	st = q.Check(row, cpu("3"), cpu("2"))
+	assert.Equal(t, "3", printQuantity(st.Available))
+	assert.True(t, st.Sum)
+	assert.False(t, q.Check(row, cpu("4"), cpu("2")).Sum)
+}
+
+func TestQuotaCheck(t *testing.T) {
+	f, err := os.OpenFile("../testdata/scope.yaml", os.O_RDONLY, 0644)
+	require.NoError(t, err)
+	assert.NotNil(t, f)
+	defer f.Close()
+	date, err := io.ReadAll(f)
+	require.NoError(t, err)
+	s := sumCmd{}
+	req, err := s.Parse(date)
+	require.NoError(t, err)
+	own, err := s.Parse(nil)
+	require.NoError(t, err)
+
+	row := resourceRow{"CPU Request", false, cv1.ResourceCPU, cv1.ResourceRequestsCPU}
+	quota := func(name string, hard string, used string, spec cv1.ResourceQuotaSpec) cv1.ResourceQuota {
+		return cv1.ResourceQuota{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       spec,
+			Status: cv1.ResourceQuotaStatus{
+				Hard: cv1.ResourceList{cv1.ResourceCPU: resource.MustParse(hard)},
+				Used: cv1.ResourceList{cv1.ResourceCPU: resource.MustParse(used)},
+			},
+		}
+	}
+
+	// tightest of unscoped quotas, short resource name is the same as requests
+	st := MergeQuotas([]cv1.ResourceQuota{
+		quota("wide", "10", "0", cv1.ResourceQuotaSpec{}),
+		quota("tight", "2", "0", cv1.ResourceQuotaSpec{}),
+	}).Check(row, req, own)
+	assert.Equal(t, "tight", st.Owner)
+	assert.True(t, st.Static)
+	assert.False(t, st.Sum)
+
+	// usage reduces available capacity unless it is release own footprint
+	st = MergeQuotas([]cv1.ResourceQuota{quota("used", "4", "2", cv1.ResourceQuotaSpec{})}).Check(row, req, own)
+	assert.Equal(t, "2", printQuantity(st.Available))
+	assert.True(t, st.Static)
+	assert.False(t, st.Sum)
+	st = MergeQuotas([]cv1.ResourceQuota{quota("used", "4", "2", cv1.ResourceQuotaSpec{})}).Check(row, req, req)
+	assert.Equal(t, "4", printQuantity(st.Available))
+	assert.True(t, st.Sum)
+
+	// scoped quotas are charged by matching pods only
+	st = MergeQuotas([]cv1.ResourceQuota{
+		quota("terminating", "600m", "0", cv1.ResourceQuotaSpec{Scopes: []cv1.ResourceQuotaScope{cv1.ResourceQuotaScopeTerminating}}),
+		quota("priority", "2", "0", cv1.ResourceQuotaSpec{ScopeSelector: &cv1.ScopeSelector{MatchExpressions: []cv1.ScopedResourceSelectorRequirement{
+			{ScopeName: cv1.ResourceQuotaScopePriorityClass, Operator: cv1.ScopeSelectorOpIn, Values: []string{"high"}},
+		}}}),
+	}).Check(row, req, own)
+	assert.True(t, st.Sum)
+	assert.Equal(t, "priority", st.Owner)
+
+	st = MergeQuotas([]cv1.ResourceQuota{
+		quota("not-best-effort", "2", "0", cv1.ResourceQuotaSpec{Scopes: []cv1.ResourceQuotaScope{cv1.ResourceQuotaScopeNotBestEffort}}),
+	}).Check(row, req, own)
+	assert.False(t, st.Sum)
+
+	// resources without quota always fit
+	st = MergeQuotas(nil).Check(row, req, own)
+	assert.True(t, st.Sum)
+	assert.Equal(t, "none", printQuantity(st.Hard))
+}
+
+func TestCheck_QuotaFile(t *testing.T) {
+	c := checkCmd{
+		baseHelmCmd: baseHelmCmd{namespace: "default"},
+		quotaFiles:  []string{"../testdata/quota.yaml"},
+	}
+	q, err := c.quota()
+	require.NoError(t, err)
+	require.Len(t, c.limitRanges, 1)
+	assert.Len(t, q.limits[cv1.ResourceRequestsCPU], 1)
+
+	f, err := os.OpenFile("../testdata/deployment.yaml", os.O_RDONLY, 0644)
+	require.NoError(t, err)
+	defer f.Close()
+	date, err := io.ReadAll(f)
+	require.NoError(t, err)
+	req, err := c.Parse(date)
+	require.NoError(t, err)
+	own, err := c.ownRequirements(req)
+	require.NoError(t, err)
+
+	buf := bytes.Buffer{}
+	require.NoError(t, c.FormatOutput(&buf, q, req, own))
+	assert.Equal(t, []string{"750m", "0", "0", "750m", "1500m", "2", "compute", "0", "2", "true", "true", "true"}, tableRow(buf.String(), "CPU Request"))
+	assert.Equal(t, []string{"1766Mi", "0", "0", "1766Mi", "3532Mi", "1Gi", "compute", "0", "1Gi", "false", "false", "false"}, tableRow(buf.String(), "Memory Request"))
+
+	ds, err := os.ReadFile("../testdata/ds.yaml")
+	require.NoError(t, err)
+	_, err = c.Parse(ds)
+	assert.ErrorContains(t, err, "--nodes")
+	c.nodes = 3
+	_, err = c.Parse(ds)
+	assert.NoError(t, err)
+}
+
+// tableRow returns trimmed cells of table row with title, nil if there is no such row
+func tableRow(table string, title string) []string {
+	for _, line := range strings.Split(table, "\n") {
+		cells := strings.Split(line, "|")
+		if len(cells) < 3 || strings.TrimSpace(cells[1]) != title {
+			continue
+		}
+		res := []string{}
+		for _, cell := range cells[2 : len(cells)-1] {
+			res = append(res, strings.TrimSpace(cell))
+		}
+		return res
+	}
+	return nil
+}
+
+func TestReport(t *testing.T) {
+	c := checkCmd{
+		baseHelmCmd: baseHelmCmd{namespace: "default", scale: scaleCurrent},
+		quotaFiles:  []string{"../testdata/quota.yaml"},
+		output:      outputJSON,
+	}
+	q, err := c.quota()
+	require.NoError(t, err)
+	f, err := os.OpenFile("../testdata/deployment.yaml", os.O_RDONLY, 0644)
+	require.NoError(t, err)
+	defer f.Close()
+	date, err := io.ReadAll(f)
+	require.NoError(t, err)
+	req, err := c.Parse(date)
+	require.NoError(t, err)
+	own, err := c.ownRequirements(req)
+	require.NoError(t, err)
+
+	buf := bytes.Buffer{}
+	require.NoError(t, c.FormatOutput(&buf, q, req, own))
+	report := Report{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &report))
+	assert.Equal(t, reportAPIVersion, report.APIVersion)
+	assert.Equal(t, "Check", report.Kind)
+	assert.Equal(t, scaleCurrent, report.Scale)
+
+	resources := map[string]ResourceReport{}
+	for _, r := range report.Resources {
+		resources[r.Resource] = r
+	}
+	cpu := resources["requests.cpu"]
+	assert.True(t, cpu.Sum.Equal(resource.MustParse("750m")), cpu.Sum)
+	assert.True(t, cpu.Quota.Equal(resource.MustParse("2")))
+	assert.Equal(t, "compute", cpu.QuotaObject)
+	assert.True(t, *cpu.OK)
+	assert.False(t, *resources["requests.memory"].OK)
+	assert.Nil(t, resources["limits.memory"].Quota)
+	assert.Nil(t, resources["configmaps"].Static)
+	assert.Nil(t, resources["configmaps"].StaticOK)
+	assert.True(t, *resources["configmaps"].OK)
+
+	s := sumCmd{baseHelmCmd: c.baseHelmCmd, output: outputYAML}
+	buf.Reset()
+	require.NoError(t, s.FormatOutput(&buf, req))
+	report = Report{}
+	require.NoError(t, yaml.Unmarshal(buf.Bytes(), &report))
+	assert.Equal(t, "Summary", report.Kind)
+	assert.Equal(t, "limits.cpu", report.Resources[0].Resource)
+	assert.True(t, report.Resources[0].Sum.Equal(resource.MustParse("1")))
+	assert.Nil(t, report.Resources[0].OK)
+}
+
+func TestVerdict(t *testing.T) {
+	f, err := os.OpenFile("../testdata/scope.yaml", os.O_RDONLY, 0644)
+	require.NoError(t, err)
+	defer f.Close()
+	date, err := io.ReadAll(f)
+	require.NoError(t, err)
+	s := sumCmd{}
+	req, err := s.Parse(date)
+	require.NoError(t, err)
+	own, err := s.Parse(nil)
+	require.NoError(t, err)
+
+	quota := func(cpu string) *Quota {
+		return MergeQuotas([]cv1.ResourceQuota{{
+			ObjectMeta: metav1.ObjectMeta{Name: "compute"},
+			Status: cv1.ResourceQuotaStatus{
+				Hard: cv1.ResourceList{cv1.ResourceRequestsCPU: resource.MustParse(cpu)},
+			},
+		}})
+	}
+	code := func(err error) int {
+		var cmdErr Error
+		if errors.As(err, &cmdErr) {
+			return cmdErr.Code
+		}
+		return 0
+	}
+
+	assert.NoError(t, verdict(quota("4"), req, own))
+	assert.Equal(t, ExitNotFitRollout, code(verdict(quota("3"), req, own)))
+	assert.Equal(t, ExitNotFitJobs, code(verdict(quota("2"), req, own)))
+	assert.Equal(t, ExitNotFit, code(verdict(quota("1"), req, own)))
+
+	req.Violations = []string{"Pod: big, Container: c: cpu limit 2 is above max 1 of LimitRange limits"}
+	assert.Equal(t, ExitNotFit, code(verdict(quota("4"), req, own)))
+
+	c := checkCmd{quotaFiles: []string{"../testdata/missing.yaml"}}
+	assert.Equal(t, ExitNotEvaluated, code(c.run()))
+}
+
+func TestBreakdown(t *testing.T) {
+	f, err := os.OpenFile("../testdata/workloads.yaml", os.O_RDONLY, 0644)
+	require.NoError(t, err)
+	defer f.Close()
+	date, err := io.ReadAll(f)
+	require.NoError(t, err)
+	b := breakdownCmd{sortBy: "requests.cpu", output: outputJSON}
+	req, err := b.Parse(date)
+	require.NoError(t, err)
+
+	buf := bytes.Buffer{}
+	require.NoError(t, b.FormatOutput(&buf, req))
+	report := BreakdownReport{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &report))
+	assert.Equal(t, "Breakdown", report.Kind)
+	names := []string{}
+	for _, w := range report.Workloads {
+		names = append(names, w.Name)
+	}
+	assert.Equal(t, []string{"rc", "rs", "migrate", "test-connection"}, names)
+	rc := report.Workloads[0]
+	assert.Equal(t, "ReplicationController", rc.Kind)
+	assert.Equal(t, "static", rc.Type)
+	assert.Equal(t, int32(3), rc.Replicas)
+	assert.True(t, rc.PerPod.Requests.Cpu().Equal(resource.MustParse("100m")))
+	assert.True(t, rc.Total.Requests.Cpu().Equal(resource.MustParse("300m")))
+	assert.Equal(t, "job", report.Workloads[2].Type)
+	assert.True(t, report.Workloads[2].Total.Requests.Cpu().Equal(resource.MustParse("150m")))
+
+	f2, err := os.OpenFile("../testdata/init.yaml", os.O_RDONLY, 0644)
+	require.NoError(t, err)
+	defer f2.Close()
+	date, err = io.ReadAll(f2)
+	require.NoError(t, err)
+	b = breakdownCmd{sortBy: "limits.cpu"}
+	req, err = b.Parse(date)
+	require.NoError(t, err)
+	containers := b.report(req).Workloads[0].Containers
+	require.Len(t, containers, 3)
+	assert.Equal(t, "migrate", containers[0].Name)
+	assert.True(t, containers[0].Init)
+	assert.True(t, containers[0].Total.Limits.Cpu().Equal(resource.MustParse("2")))
+	assert.Equal(t, "container1", containers[1].Name)
+
+	buf.Reset()
+	require.NoError(t, b.FormatOutput(&buf, req))
+	assert.Contains(t, buf.String(), "|                              |init:migrate      |           |          |             2 |")
+}
+
+func TestBreakdown_Claims(t *testing.T) {
+	date, err := os.ReadFile("../testdata/sc.yaml")
+	require.NoError(t, err)
+	b := breakdownCmd{sortBy: "requests.storage"}
+	b.defaultStorageClass = "standard"
+	req, err := b.Parse(date)
+	require.NoError(t, err)
+
+	claims := b.report(req).Claims
+	require.Len(t, claims, 3)
+	assert.Equal(t, "StatefulSet", claims[0].Kind)
+	assert.Equal(t, "data", claims[0].Template)
+	assert.Equal(t, "fast", claims[0].StorageClass)
+	assert.Equal(t, int32(2), claims[0].Replicas)
+	assert.True(t, claims[0].PerClaim.Equal(resource.MustParse("10Gi")), claims[0].PerClaim)
+	assert.True(t, claims[0].Total.Equal(resource.MustParse("20Gi")), claims[0].Total)
+	assert.Equal(t, "standard", claims[1].StorageClass)
+	assert.Equal(t, "no-class", claims[2].Name)
+
+	buf := bytes.Buffer{}
+	require.NoError(t, b.FormatOutput(&buf, req))
+	assert.Equal(t, []string{"", "", "1", "1Gi", "1Gi"}, tableRow(buf.String(), "PersistentVolumeClaim/no-class"))
+	assert.Contains(t, buf.String(), "| Claim ")
+}
+
+func TestDiff(t *testing.T) {
+	parse := func(path string) *Requirements {
+		date, err := os.ReadFile(path)
+		require.NoError(t, err)
+		req, err := sumCmd{}.Parse(date)
+		require.NoError(t, err)
+		return req
+	}
+	d := diffCmd{output: outputJSON}
+	report := d.report(parse("../testdata/deployment.yaml"), parse("../testdata/deployment2.yaml"))
+
+	resources := map[string]ResourceDelta{}
+	for _, r := range report.Resources {
+		resources[r.Resource] = r
+	}
+	assert.True(t, resources["limits.cpu"].Old.Equal(resource.MustParse("500m")), resources["limits.cpu"].Old)
+	assert.True(t, resources["limits.cpu"].New.Equal(resource.MustParse("1350m")))
+	assert.True(t, resources["limits.cpu"].Delta.Equal(resource.MustParse("850m")))
+	assert.True(t, resources["requests.cpu"].Delta.Equal(resource.MustParse("350m")), resources["requests.cpu"].Delta)
+	assert.True(t, resources["configmaps"].Delta.Equal(resource.MustParse("0")))
+
+	require.Len(t, report.Workloads, 1)
+	assert.Equal(t, int32(1), report.Workloads[0].OldReplicas)
+	assert.Equal(t, int32(3), report.Workloads[0].NewReplicas)
+	assert.True(t, report.Workloads[0].Delta.Requests.Cpu().Equal(resource.MustParse("350m")))
+
+	buf := bytes.Buffer{}
+	require.NoError(t, d.FormatOutput(&buf, report))
+	decoded := DiffReport{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	assert.Equal(t, "Diff", decoded.Kind)
+
+	d.output = ""
+	buf.Reset()
+	require.NoError(t, d.FormatOutput(&buf, report))
+	assert.Contains(t, buf.String(), "|CPU Request       |          250m |          600m |         +350m |")
+
+	exceededBy := func(thresholds map[string]string) error {
+		d.thresholds = thresholds
+		parsed, err := d.parseThresholds(report)
+		if err != nil {
+			return err
+		}
+		return exceeded(report, parsed)
+	}
+	assert.NoError(t, exceededBy(map[string]string{"cpu": "1"}))
+	var cmdErr Error
+	require.True(t, errors.As(exceededBy(map[string]string{"cpu": "300m"}), &cmdErr))
+	assert.Equal(t, ExitThresholdExceeded, cmdErr.Code)
+
+	err := exceededBy(map[string]string{"requests.cpus": "1"})
+	require.Error(t, err)
+	assert.False(t, errors.As(err, &cmdErr))
+	assert.Contains(t, err.Error(), "requests.cpus")
+	assert.NoError(t, exceededBy(map[string]string{"requests.nvidia.com/gpu": "1", "fast.storageclass.storage.k8s.io/requests.storage": "10Gi"}))
+}
+
+func TestDiffSides(t *testing.T) {
+	d := diffCmd{
+		baseHelmCmd: baseHelmCmd{repo: "https://new.example.com", showOnly: []string{"templates/new.yaml"}, values: []string{"a=2"}, kubeVersion: "1.29"},
+		oldValues:   []string{"a=1"},
+		oldVersion:  "1.0.0",
+	}
+	oldCmd, newCmd := d.sides([]string{"chart-old", "chart-new"})
+	assert.Equal(t, "chart-old", oldCmd.chart)
+	assert.Equal(t, "chart-new", newCmd.chart)
+	assert.Equal(t, []string{"a=1"}, oldCmd.values)
+	assert.Equal(t, "1.0.0", oldCmd.version)
+	assert.Equal(t, "https://new.example.com", oldCmd.repo)
+	assert.Equal(t, []string{"templates/new.yaml"}, oldCmd.showOnly)
+	assert.Equal(t, "1.29", oldCmd.kubeVersion)
+
+	d.oldRepo = "https://old.example.com"
+	d.oldShowOnly = []string{"templates/old.yaml"}
+	oldCmd, newCmd = d.sides([]string{"chart"})
+	assert.Equal(t, "chart", newCmd.chart)
+	assert.Equal(t, "https://old.example.com", oldCmd.repo)
+	assert.Equal(t, []string{"templates/old.yaml"}, oldCmd.showOnly)
+	assert.Equal(t, "https://new.example.com", newCmd.repo)
+	assert.Equal(t, []string{"templates/new.yaml"}, newCmd.showOnly)
+}
+
+func TestSdkTemplate(t *testing.T) {
+	b := baseHelmCmd{chart: "../testdata/chart", namespace: "default", values: []string{"replicas=3"}}
+	manifest, err := b.sdkTemplate()
+	require.NoError(t, err)
+	req, err := sumCmd{}.Parse(manifest)
+	require.NoError(t, err)
+	assert.True(t, req.Requests.Cpu().Equal(resource.MustParse("300m")), req.Requests.Cpu())
+	assert.True(t, req.Requests.Name(jobCpu, resource.DecimalSI).Equal(resource.MustParse("50m")))
+	require.Len(t, req.Workloads, 3)
+	assert.Equal(t, "release-name-web", req.Workloads[0].Name)
+
+	b.releaseName = "app"
+	b.stringValues = []string{"cpu=200m"}
+	b.showOnly = []string{"templates/deployment.yaml"}
+	manifest, err = b.sdkTemplate()
+	require.NoError(t, err)
+	req, err = sumCmd{}.Parse(manifest)
+	require.NoError(t, err)
+	assert.True(t, req.Requests.Cpu().Equal(resource.MustParse("600m")), req.Requests.Cpu())
+	assert.True(t, req.Requests.Name(jobCpu, resource.DecimalSI).IsZero())
+	assert.Equal(t, "app-web", req.Workloads[0].Name)
+
+	b.showOnly = []string{"templates/missing.yaml"}
+	_, err = b.sdkTemplate()
+	assert.Error(t, err)
+	b.showOnly = nil
+	b.kubeVersion = "invalid"
+	_, err = b.sdkTemplate()
+	assert.Error(t, err)
+
+	_, err = baseHelmCmd{chart: "../testdata/missing"}.sdkTemplate()
+	assert.Error(t, err)
+}
+
+func TestSdkTemplate_OCI(t *testing.T) {
+	t.Setenv("HELM_REGISTRY_CONFIG", filepath.Join(t.TempDir(), "config.json"))
+	t.Setenv("HELM_CACHE_HOME", t.TempDir())
+	requested := []string{}
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.Path)
+		http.NotFound(w, r)
+	}))
+	defer registry.Close()
+
+	chart := "oci://" + strings.TrimPrefix(registry.URL, "http://") + "/charts/app"
+	_, err := baseHelmCmd{chart: chart, version: "1.0.0"}.sdkTemplate()
+	require.Error(t, err)
+	assert.NotContains(t, err.Error(), "missing registry client")
+	assert.Contains(t, requested, "/v2/charts/app/manifests/1.0.0")
+}
+
+func TestRenderFallback(t *testing.T) {
+	sdk := func() ([]byte, error) { return nil, errors.New("sdk failed") }
+	exec := func() ([]byte, error) { return []byte("exec"), nil }
+
+	t.Setenv("HELM_BIN", "")
+	_, err := render(engineAuto, sdk, exec)
+	assert.EqualError(t, err, "sdk failed")
+
+	t.Setenv("HELM_BIN", "helm")
+	manifest, err := render(engineAuto, sdk, exec)
+	require.NoError(t, err)
+	assert.Equal(t, "exec", string(manifest))
+
+	_, err = render(engineAuto, sdk, func() ([]byte, error) { return nil, errors.New("plugin env broken") })
+	assert.ErrorContains(t, err, "sdk failed")
+	assert.ErrorContains(t, err, "plugin env broken")
+
+	_, err = render(engineSDK, sdk, exec)
+	assert.Error(t, err)
+	_, err = render("other", sdk, exec)
+	assert.Error(t, err)
+}
+
+func TestGetTemplateArgs(t *testing.T) {
+	t.Setenv("HELM_BIN", "echo")
+	b := baseHelmCmd{
+		chart:        "repo/chart",
+		namespace:    "default",
+		releaseName:  "app",
+		values:       []string{"a=1"},
+		stringValues: []string{"b=2"},
+		version:      "1.2.3",
+		apiVersions:  []string{"monitoring.coreos.com/v1"},
+		includeCRDs:  true,
+		showOnly:     []string{"templates/deployment.yaml"},
+	}
+	saved := kube
+	defer func() { kube = saved }()
+	kube = kubeOptions{}
+	out, err := b.getTemplate()
+	require.NoError(t, err)
+	assert.Equal(t, "template app repo/chart --namespace default --set a=1 --set-string b=2 --version 1.2.3 --api-versions monitoring.coreos.com/v1 --show-only templates/deployment.yaml --include-crds\n", string(out))
+
+	kube = kubeOptions{kubeconfig: "/tmp/config", context: "prod", asGroups: []string{"ops"}}
+	out, err = getRelease("app", "default")
+	require.NoError(t, err)
+	assert.Equal(t, "get manifest app --namespace default --kubeconfig /tmp/config --kube-context prod --kube-as-group ops\n", string(out))
+}
+
+func TestReadManifests(t *testing.T) {
+	b := baseHelmCmd{manifests: []string{"../testdata/manifests", "../testdata/deployment2.yaml"}}
+	req, err := b.GetRequirements()
+	require.NoError(t, err)
+	assert.True(t, req.Requests.Cpu().Equal(resource.MustParse("810m")), req.Requests.Cpu())
+	assert.True(t, req.Requests.Name(jobCpu, resource.DecimalSI).Equal(resource.MustParse("50m")))
+
+	_, err = readManifests([]string{"../testdata/missing"})
+	assert.Error(t, err)
+
+	// explicitly named files are read even if they would be skipped in directory
+	manifest, err := readManifests([]string{"../testdata/manifests/values.yaml"})
+	require.NoError(t, err)
+	assert.Contains(t, string(manifest), "kind: Pod")
+
+	_, err = readManifests([]string{"-", "-"})
+	assert.ErrorContains(t, err, "stdin")
+	assert.Error(t, stdinOnce([]string{"-"}, []string{"-"}))
+	assert.NoError(t, stdinOnce([]string{"-"}, []string{"quota.yaml"}))
+}
+
+func TestKubeClientConfig(t *testing.T) {
+	kubeconfig := filepath.Join(t.TempDir(), "config")
+	require.NoError(t, os.WriteFile(kubeconfig, []byte(`apiVersion: v1
+kind: Config
+current-context: dev
+clusters:
+  - name: dev
+    cluster:
+      server: https://dev.example.com
+  - name: prod
+    cluster:
+      server: https://prod.example.com
+contexts:
+  - name: dev
+    context:
+      cluster: dev
+      user: user
+  - name: prod
+    context:
+      cluster: prod
+      user: user
+users:
+  - name: user
+    user:
+      token: secret
+`), 0600))
+
+	k := kubeOptions{kubeconfig: kubeconfig}
+	config, err := k.clientConfig().ClientConfig()
+	require.NoError(t, err)
+	assert.Equal(t, "https://dev.example.com", config.Host)
+
+	k.context = "prod"
+	k.asUser = "admin"
+	k.asGroups = []string{"ops"}
+	config, err = k.clientConfig().ClientConfig()
+	require.NoError(t, err)
+	assert.Equal(t, "https://prod.example.com", config.Host)
+	assert.Equal(t, "secret", config.BearerToken)
+	assert.Equal(t, "admin", config.Impersonate.UserName)
+	assert.Equal(t, []string{"ops"}, config.Impersonate.Groups)
+
+	k.apiServer = "https://api.example.com"
+	k.token = "token"
+	config, err = k.clientConfig().ClientConfig()
+	require.NoError(t, err)
+	assert.Equal(t, "https://api.example.com", config.Host)
+	assert.Equal(t, "token", config.BearerToken)
+}
+
+func TestReleases(t *testing.T) {
+	web, err := os.ReadFile("../testdata/deployment.yaml")
+	require.NoError(t, err)
+	db, err := os.ReadFile("../testdata/deployment2.yaml")
+	require.NoError(t, err)
+	ds, err := os.ReadFile("../testdata/ds.yaml")
+	require.NoError(t, err)
+	rels := []deployedRelease{
+		{Name: "web", Namespace: "default", Chart: "web-1.0.0", Manifest: web},
+		{Name: "agent", Namespace: "default", Chart: "agent-1.0.0", Manifest: ds},
+		{Name: "other", Namespace: "other", Manifest: db},
+		{Name: "db", Namespace: "default", Chart: "db-2.0.0", Manifest: db},
+		{Name: "agent", Namespace: "other", Manifest: ds},
+	}
+	lookups := 0
+	r := releasesCmd{baseHelmCmd: baseHelmCmd{scale: scaleCurrent}}
+	r.nodeCounts = cachedNodeCount(func(map[string]string) (int32, error) {
+		lookups++
+		return 0, apierrors.NewForbidden(schema.GroupResource{Resource: "nodes"}, "", errors.New("no access"))
+	})
+	namespaces, err := r.namespaces(rels, func(nr *namespaceReleases) error {
+		if nr.namespace != "default" {
+			return nr.forbidden(apierrors.NewForbidden(schema.GroupResource{Resource: "resourcequotas"}, "", errors.New("no access")))
+		}
+		q, _, err := LoadQuotaFiles([]string{"../testdata/quota.yaml"}, nr.namespace)
+		nr.quota = q
+		return err
+	})
+	require.NoError(t, err)
+	require.Len(t, namespaces, 2)
+	assert.Equal(t, "default", namespaces[0].namespace)
+	assert.Len(t, namespaces[0].releases, 2)
+	assert.Contains(t, namespaces[1].quotaError, "forbidden")
+	require.Len(t, namespaces[0].failed, 1)
+	assert.Equal(t, "agent", namespaces[0].failed[0].Name)
+	assert.Contains(t, namespaces[0].failed[0].err, "nodes")
+	require.Len(t, namespaces[1].failed, 1)
+	assert.Equal(t, 1, lookups)
+
+	other := namespaceReleases{namespace: "other"}
+	assert.Error(t, other.forbidden(errors.New("connection refused")))
+
+	buf := bytes.Buffer{}
+	require.NoError(t, r.FormatOutput(&buf, namespaces))
+	out := buf.String()
+	assert.Equal(t, []string{"500m", "1600Mi", "0", "250m", "1510Mi"}, tableRow(out, "web")[:5])
+	assert.Equal(t, []string{"1850m", "6100Mi", "0", "850m", "6010Mi"}, tableRow(out, "Total")[:5])
+	assert.Equal(t, []string{"4", "none", "none", "2", "1Gi"}, tableRow(out, "Quota")[:5])
+	assert.Equal(t, 1, strings.Count(out, "|Quota used"))
+	shares := out[strings.Index(out, "Share of quota"):]
+	assert.Equal(t, []string{"12.5%", "none", "none", "12.5%", "147.5%"}, tableRow(shares, "web")[:5])
+	assert.Equal(t, []string{"33.8%", "none", "none", "30.0%", "439.5%"}, tableRow(shares, "db")[:5])
+	assert.Contains(t, out, "Quota not available")
+	assert.Contains(t, out, "Release agent not evaluated")
+
+	r.output = outputJSON
+	buf.Reset()
+	require.NoError(t, r.FormatOutput(&buf, namespaces))
+	report := ReleasesReport{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &report))
+	assert.Equal(t, "Releases", report.Kind)
+	require.Len(t, report.Namespaces, 2)
+	assert.Equal(t, "db-2.0.0", report.Namespaces[0].Releases[1].Chart)
+	require.Len(t, report.Namespaces[0].Releases, 3)
+	assert.Equal(t, "agent-1.0.0", report.Namespaces[0].Releases[2].Chart)
+	assert.Empty(t, report.Namespaces[0].Releases[2].Resources)
+	assert.Contains(t, report.Namespaces[0].Releases[2].Error, "nodes")
+	for _, rr := range report.Namespaces[0].Total {
+		switch rr.Resource {
+		case "requests.cpu":
+			assert.True(t, rr.Sum.Equal(resource.MustParse("850m")), rr.Sum)
+			assert.True(t, *rr.OK)
+		case "requests.memory":
+			assert.False(t, *rr.OK)
+		}
+	}
+	for _, rr := range report.Namespaces[0].Releases[1].Resources {
+		switch rr.Resource {
+		case "requests.cpu":
+			require.NotNil(t, rr.QuotaShare)
+			assert.Equal(t, 30.0, *rr.QuotaShare)
+		case "limits.memory":
+			assert.Nil(t, rr.QuotaShare)
+		}
+	}
+	assert.Nil(t, report.Namespaces[1].Total[0].Quota)
+	assert.Nil(t, report.Namespaces[1].Releases[0].Resources[0].QuotaShare)
+	assert.NotEmpty(t, report.Namespaces[1].QuotaError)
+}
+
+func TestValidateOutput(t *testing.T) {
+	for _, args := range [][]string{
+		{"sum", "--manifest", "../testdata/deployment.yaml", "--output", "jsno"},
+		{"check", "--manifest", "../testdata/deployment.yaml", "--output", "text"},
+		{"breakdown", "--manifest", "../testdata/deployment.yaml", "--output", "jsno"},
+		{"diff", "a", "b", "--output", "jsno"},
+		{"releases", "--output", "jsno"},
+	} {
+		c := New()
+		c.SetArgs(args)
+		c.SetOut(io.Discard)
+		c.SetErr(io.Discard)
+		err := c.Execute()
+		require.Error(t, err, args)
+		assert.Contains(t, err.Error(), "unknown output format", args)
+		assert.Contains(t, err.Error(), "allowed: ", args)
+	}
+	assert.NoError(t, validateOutput("", outputTable, outputJSON, outputYAML))
+	assert.NoError(t, validateOutput(outputTable, outputTable, outputJSON, outputYAML))
+	assert.NoError(t, validateOutput(outputText, outputText, outputTable, outputJSON, outputYAML))
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"fmt"
+
+	asv2 "k8s.io/api/autoscaling/v2"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	scaleCurrent = "current"
+	scaleMin     = "min"
+	scaleMax     = "max"
+	scaleAll     = "all"
+)
+
+// autoscaler is replicas range of HorizontalPodAutoscaler target
+type autoscaler struct {
+	min int32
+	max int32
+}
+
+// collectAutoscalers finds HorizontalPodAutoscalers in manifest documents indexed by target API group, kind and name
+func collectAutoscalers(docs [][]byte) map[string]autoscaler {
+	res := map[string]autoscaler{}
+	for _, content := range docs {
+		hpa := asv2.HorizontalPodAutoscaler{}
+		if err := yaml.Unmarshal(content, &hpa); err != nil {
+			// assume yaml is valid and error caused type incompatibility
+			continue
+		}
+		if hpa.Kind != "HorizontalPodAutoscaler" {
+			continue
+		}
+		a := autoscaler{min: 1, max: hpa.Spec.MaxReplicas}
+		if hpa.Spec.MinReplicas != nil {
+			a.min = *hpa.Spec.MinReplicas
+		}
+		ref := hpa.Spec.ScaleTargetRef
+		res[autoscalerKey(ref.APIVersion, ref.Kind, ref.Name)] = a
+	}
+	return res
+}
+
+// autoscalerKey identifies scale target by API group, so any version of the group matches
+func autoscalerKey(apiVersion, kind, name string) string {
+	group := ""
+	if gv, err := schema.ParseGroupVersion(apiVersion); err == nil {
+		group = gv.Group
+	}
+	return fmt.Sprintf("%s/%s/%s", group, kind, name)
+}
+
+// replicas returns workload replica count for selected scale, taking autoscaler range into account
+func (b baseHelmCmd) replicas(apiVersion, kind, name string, replicas *int32) int32 {
+	repl := int32(1)
+	if replicas != nil {
+		repl = *replicas
+	}
+	a, ok := b.autoscalers[autoscalerKey(apiVersion, kind, name)]
+	if !ok {
+		return repl
+	}
+	switch b.scale {
+	case scaleMin:
+		return a.min
+	case scaleMax:
+		return a.max
+	}
+	return min(max(repl, a.min), a.max)
+}
@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/pflag"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// kubeOptions selects cluster the way helm does, defaults come from environment helm passes to plugins
+type kubeOptions struct {
+	kubeconfig    string
+	context       string
+	apiServer     string
+	token         string
+	caFile        string
+	insecure      bool
+	tlsServerName string
+	asUser        string
+	asGroups      []string
+}
+
+var kube kubeOptions
+
+func (k *kubeOptions) addFlags(f *pflag.FlagSet) {
+	insecure, _ := strconv.ParseBool(os.Getenv("HELM_KUBEINSECURE_SKIP_TLS_VERIFY"))
+	groups := []string{}
+	if g := os.Getenv("HELM_KUBEASGROUPS"); g != "" {
+		groups = strings.Split(g, ",")
+	}
+
+	f.StringVar(&k.kubeconfig, "kubeconfig", "", "Path to the kubeconfig file (KUBECONFIG and ~/.kube/config are used if not set)")
+	f.StringVar(&k.context, "kube-context", os.Getenv("HELM_KUBECONTEXT"), "Name of the kubeconfig context to use")
+	f.StringVar(&k.apiServer, "kube-apiserver", os.Getenv("HELM_KUBEAPISERVER"), "Address and the port for the Kubernetes API server")
+	f.StringVar(&k.token, "kube-token", os.Getenv("HELM_KUBETOKEN"), "Bearer token used for authentication")
+	f.StringVar(&k.caFile, "kube-ca-file", os.Getenv("HELM_KUBECAFILE"), "Certificate authority file for the Kubernetes API server connection")
+	f.BoolVar(&k.insecure, "kube-insecure-skip-tls-verify", insecure, "Do not verify Kubernetes API server certificate")
+	f.StringVar(&k.tlsServerName, "kube-tls-server-name", os.Getenv("HELM_KUBETLS_SERVER_NAME"), "Server name used for Kubernetes API server certificate validation")
+	f.StringVar(&k.asUser, "as", os.Getenv("HELM_KUBEASUSER"), "Username to impersonate for the operation")
+	f.StringArrayVar(&k.asGroups, "as-group", groups, "Group to impersonate for the operation (can specify multiple)")
+}
+
+// helmArgs returns helm global flags selecting the same cluster, so helm binary queries the cluster SDK does
+func (k kubeOptions) helmArgs() []string {
+	args := []string{}
+	for _, f := range []struct{ name, value string }{
+		{"--kubeconfig", k.kubeconfig},
+		{"--kube-context", k.context},
+		{"--kube-apiserver", k.apiServer},
+		{"--kube-token", k.token},
+		{"--kube-ca-file", k.caFile},
+		{"--kube-tls-server-name", k.tlsServerName},
+		{"--kube-as-user", k.asUser},
+	} {
+		if f.value != "" {
+			args = append(args, f.name, f.value)
+		}
+	}
+	for _, g := range k.asGroups {
+		args = append(args, "--kube-as-group", g)
+	}
+	if k.insecure {
+		args = append(args, "--kube-insecure-skip-tls-verify")
+	}
+	return args
+}
+
+// clientConfig resolves kubeconfig with loading rules (KUBECONFIG, default path, in-cluster config) and overrides
+func (k kubeOptions) clientConfig() clientcmd.ClientConfig {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = k.kubeconfig
+
+	overrides := &clientcmd.ConfigOverrides{}
+	overrides.CurrentContext = k.context
+	overrides.ClusterInfo.Server = k.apiServer
+	overrides.ClusterInfo.CertificateAuthority = k.caFile
+	overrides.ClusterInfo.InsecureSkipTLSVerify = k.insecure
+	overrides.ClusterInfo.TLSServerName = k.tlsServerName
+	overrides.AuthInfo.Token = k.token
+	overrides.AuthInfo.Impersonate = k.asUser
+	overrides.AuthInfo.ImpersonateGroups = k.asGroups
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides)
+}
+
+func newClientset() (*kubernetes.Clientset, error) {
+	config, err := kube.clientConfig().ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	return kubernetes.NewForConfig(config)
+}
+
+// GetNodeCount returns number of cluster nodes matching node selector
+func GetNodeCount(selector map[string]string) (int32, error) {
+	clientset, err := newClientset()
+	if err != nil {
+		return 0, err
+	}
+	nl, err := clientset.CoreV1().Nodes().List(context.TODO(), v1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(selector).String(),
+	})
+	if err != nil {
+		return 0, err
+	}
+	return int32(len(nl.Items)), nil
+}
+
+// cachedNodeCount returns node count lookup made once per node selector, errors included, so DaemonSets sharing
+// selector across releases do not list nodes again
+func cachedNodeCount(count func(selector map[string]string) (int32, error)) func(map[string]string) (int32, error) {
+	type result struct {
+		nodes int32
+		err   error
+	}
+	results := map[string]result{}
+	return func(selector map[string]string) (int32, error) {
+		key := labels.SelectorFromSet(selector).String()
+		res, ok := results[key]
+		if !ok {
+			res.nodes, res.err = count(selector)
+			results[key] = res
+		}
+		return res.nodes, res.err
+	}
+}
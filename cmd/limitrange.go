@@ -0,0 +1,208 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+
+	cv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// GetLimitRanges returns LimitRanges defined in namespace
+func GetLimitRanges(namespace string) ([]cv1.LimitRange, error) {
+	clientset, err := newClientset()
+	if err != nil {
+		return nil, err
+	}
+	lrl, err := clientset.CoreV1().LimitRanges(namespace).List(context.TODO(), v1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return lrl.Items, nil
+}
+
+// collectLimitRanges finds LimitRanges applying to namespace in manifest documents
+func collectLimitRanges(docs [][]byte, namespace string) []cv1.LimitRange {
+	res := []cv1.LimitRange{}
+	for _, content := range docs {
+		lr := cv1.LimitRange{}
+		if err := yaml.Unmarshal(content, &lr); err != nil {
+			// assume yaml is valid and error caused type incompatibility
+			continue
+		}
+		if lr.Kind != "LimitRange" || (lr.Namespace != "" && namespace != "" && lr.Namespace != namespace) {
+			continue
+		}
+		defaultLimitRange(&lr)
+		res = append(res, lr)
+	}
+	return res
+}
+
+// defaultLimitRange fills container defaults the way API server does:
+// default limit falls back to max, default request to default limit and then to min
+func defaultLimitRange(lr *cv1.LimitRange) {
+	for i := range lr.Spec.Limits {
+		item := &lr.Spec.Limits[i]
+		if item.Type != cv1.LimitTypeContainer {
+			continue
+		}
+		if item.Default == nil {
+			item.Default = cv1.ResourceList{}
+		}
+		if item.DefaultRequest == nil {
+			item.DefaultRequest = cv1.ResourceList{}
+		}
+		for r, v := range item.Max {
+			if _, ok := item.Default[r]; !ok {
+				item.Default[r] = v.DeepCopy()
+			}
+		}
+		for _, src := range []cv1.ResourceList{item.Default, item.Min} {
+			for r, v := range src {
+				if _, ok := item.DefaultRequest[r]; !ok {
+					item.DefaultRequest[r] = v.DeepCopy()
+				}
+			}
+		}
+	}
+}
+
+// containerResources returns container requirements defaulted the way they are admitted, with values injected by LimitRanges.
+// As in API server a request omitted next to explicit limit defaults to that limit before LimitRange is consulted.
+func (b baseHelmCmd) containerResources(c cv1.Container) cv1.ResourceRequirements {
+	rr := cv1.ResourceRequirements{
+		Limits:   cv1.ResourceList{},
+		Requests: cv1.ResourceList{},
+	}
+	for r, v := range c.Resources.Limits {
+		rr.Limits[r] = v
+		rr.Requests[r] = v
+	}
+	for r, v := range c.Resources.Requests {
+		rr.Requests[r] = v
+	}
+	merge := func(src cv1.ResourceList, dst cv1.ResourceList) {
+		for r, v := range src {
+			if _, ok := dst[r]; !ok {
+				dst[r] = v
+			}
+		}
+	}
+	for _, lr := range b.limitRanges {
+		for _, item := range lr.Spec.Limits {
+			if item.Type == cv1.LimitTypeContainer {
+				merge(item.Default, rr.Limits)
+				merge(item.DefaultRequest, rr.Requests)
+			}
+		}
+	}
+	return rr
+}
+
+// podViolations reports containers and pod totals which LimitRanges would not admit
+func (b baseHelmCmd) podViolations(pathid string, spec cv1.PodSpec, pod *cv1.ResourceRequirements) []string {
+	res := []string{}
+	for _, lr := range b.limitRanges {
+		for _, item := range lr.Spec.Limits {
+			switch item.Type {
+			case cv1.LimitTypeContainer:
+				for _, c := range spec.InitContainers {
+					res = append(res, limitViolations(fmt.Sprintf("%s, InitContainer: %s", pathid, c.Name), lr.Name, item, b.containerResources(c))...)
+				}
+				for _, c := range spec.Containers {
+					res = append(res, limitViolations(fmt.Sprintf("%s, Container: %s", pathid, c.Name), lr.Name, item, b.containerResources(c))...)
+				}
+			case cv1.LimitTypePod:
+				res = append(res, limitViolations(pathid, lr.Name, item, *pod)...)
+			}
+		}
+	}
+	return res
+}
+
+// claimViolations reports claim storage requests which LimitRanges would not admit
+func (b baseHelmCmd) claimViolations(pathid string, spec cv1.PersistentVolumeClaimSpec) []string {
+	res := []string{}
+	for _, lr := range b.limitRanges {
+		for _, item := range lr.Spec.Limits {
+			if item.Type == cv1.LimitTypePersistentVolumeClaim {
+				res = append(res, limitViolations(pathid, lr.Name, item, cv1.ResourceRequirements{Limits: spec.Resources.Limits, Requests: spec.Resources.Requests})...)
+			}
+		}
+	}
+	return res
+}
+
+// limitViolations checks requirements against min, max and maxLimitRequestRatio of LimitRange item.
+// Values not defined are skipped.
+func limitViolations(pathid string, owner string, item cv1.LimitRangeItem, rr cv1.ResourceRequirements) []string {
+	res := []string{}
+	violation := func(r cv1.ResourceName, what string, v resource.Quantity, bound string, b resource.Quantity) {
+		cmp := "above"
+		if bound == "min" {
+			cmp = "below"
+		}
+		res = append(res, fmt.Sprintf("%s: %s %s %v is %s %s %v of LimitRange %s", pathid, r, what, &v, cmp, bound, &b, owner))
+	}
+	for _, r := range resourceNames(item.Min, item.Max, item.MaxLimitRequestRatio) {
+		req, hasReq := rr.Requests[r]
+		limit, hasLimit := rr.Limits[r]
+		hasReq = hasReq && !req.IsZero()
+		hasLimit = hasLimit && !limit.IsZero()
+
+		if m, ok := item.Min[r]; ok {
+			if hasReq && req.Cmp(m) < 0 {
+				violation(r, "request", req, "min", m)
+			}
+			if hasLimit && limit.Cmp(m) < 0 {
+				violation(r, "limit", limit, "min", m)
+			}
+		}
+		if m, ok := item.Max[r]; ok {
+			if hasReq && req.Cmp(m) > 0 {
+				violation(r, "request", req, "max", m)
+			}
+			if hasLimit && limit.Cmp(m) > 0 {
+				violation(r, "limit", limit, "max", m)
+			}
+		}
+		if m, ok := item.MaxLimitRequestRatio[r]; ok && hasReq && hasLimit {
+			ratio := limit.AsApproximateFloat64() / req.AsApproximateFloat64()
+			if ratio > m.AsApproximateFloat64() {
+				violation(r, "limit to request ratio", *resource.NewMilliQuantity(int64(ratio*1000), resource.DecimalSI), "maxLimitRequestRatio", m)
+			}
+		}
+	}
+	return res
+}
+
+// resourceNames returns sorted names of resources defined in any of lists
+func resourceNames(lists ...cv1.ResourceList) []cv1.ResourceName {
+	names := []cv1.ResourceName{}
+	seen := map[cv1.ResourceName]bool{}
+	for _, rl := range lists {
+		for r := range rl {
+			if !seen[r] {
+				seen[r] = true
+				names = append(names, r)
+			}
+		}
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+	return names
+}
+
+// printViolations writes LimitRange violations found in requirements
+func printViolations(w io.Writer, req *Requirements) error {
+	for _, v := range req.Violations {
+		if _, err := fmt.Fprintf(w, "LimitRange violation: %s\n", v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
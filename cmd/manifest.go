@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// manifestExtensions are file extensions read from manifest directories
+var manifestExtensions = []string{".yaml", ".yml", ".json"}
+
+// nonManifestFiles are patterns of yaml files found next to manifests which are not manifests
+var nonManifestFiles = []string{"values*.yaml", "values*.yml", "Chart.yaml", "kustomization.yaml", "kustomization.yml"}
+
+// stdinOnce rejects reading stdin for more than one input, the second reader would get nothing
+func stdinOnce(paths ...[]string) error {
+	count := 0
+	for _, p := range paths {
+		for _, path := range p {
+			if path == "-" {
+				count++
+			}
+		}
+	}
+	if count > 1 {
+		return errors.New("stdin (-) can be read only once, pass other inputs as files")
+	}
+	return nil
+}
+
+// readManifests joins multi-document yaml read from files, directories (recursively, skipping hidden directories
+// and non-manifest yaml) and stdin for "-"
+func readManifests(paths []string) ([]byte, error) {
+	if err := stdinOnce(paths); err != nil {
+		return nil, err
+	}
+	manifest := bytes.Buffer{}
+	add := func(data []byte) {
+		manifest.Write(yamlSeparator)
+		manifest.Write(data)
+	}
+	for _, path := range paths {
+		if path == "-" {
+			data, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				return nil, err
+			}
+			add(data)
+			continue
+		}
+		err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if p != path && strings.HasPrefix(d.Name(), ".") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if p != path && !isManifestFile(p) {
+				return nil
+			}
+			data, err := os.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			add(data)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return manifest.Bytes(), nil
+}
+
+// isManifestFile reports whether file found in manifest directory is yaml or json manifest
+func isManifestFile(path string) bool {
+	for _, pattern := range nonManifestFiles {
+		if matched, _ := filepath.Match(pattern, filepath.Base(path)); matched {
+			return false
+		}
+	}
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, e := range manifestExtensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
@@ -1,9 +1,9 @@
 package cmd
 
 import (
-	"bufio"
-	"bytes"
+	"errors"
 	"fmt"
+	"os"
 
 	appsv1 "k8s.io/api/apps/v1"
 	bav1 "k8s.io/api/batch/v1"
@@ -17,62 +17,111 @@ var ZERO = resource.MustParse("0")
 var UNO = resource.MustParse("1")
 
 const (
-	jobCpu     = "x-job-cpu"
-	jobMemory  = "x-job-memory"
-	jobStorage = "x-job-storage"
-)
+	jobPrefix = "x-job-"
+	dsPrefix  = "x-ds-"
 
-type TypeParser func(content []byte, cr *cv1.ResourceRequirements) (bool, error)
+	jobCpu       = jobPrefix + cv1.ResourceCPU
+	jobMemory    = jobPrefix + cv1.ResourceMemory
+	jobStorage   = jobPrefix + cv1.ResourceStorage
+	jobEphemeral = jobPrefix + cv1.ResourceEphemeralStorage
 
-func (b baseHelmCmd) GetRequirements() (*cv1.ResourceRequirements, error) {
-	var manifest []byte
-	var err error
-	if b.remote {
-		manifest, err = getRelease(b.chart, b.namespace)
-	} else {
-		manifest, err = getTemplate(b.chart, b.namespace, b.values, b.valueFiles)
-	}
+	dsCpu       = dsPrefix + cv1.ResourceCPU
+	dsMemory    = dsPrefix + cv1.ResourceMemory
+	dsEphemeral = dsPrefix + cv1.ResourceEphemeralStorage
+)
+
+type TypeParser func(content []byte, cr *Requirements) (bool, error)
 
+func (b baseHelmCmd) GetRequirements() (*Requirements, error) {
+	manifest, err := b.GetManifest()
 	if err != nil {
 		return nil, err
 	}
 	return b.Parse(manifest)
 }
 
-func (b baseHelmCmd) Parse(manifest []byte) (*cv1.ResourceRequirements, error) {
-	scanner := bufio.NewScanner(bytes.NewReader(manifest))
-	scanner.Split(scanYamlSpecs)
-	scanner.Buffer(make([]byte, bufio.MaxScanTokenSize), 10485760)
+// GetManifest reads manifest files, renders local chart or fetches manifest of remote release
+func (b baseHelmCmd) GetManifest() ([]byte, error) {
+	if len(b.manifests) > 0 {
+		return readManifests(b.manifests)
+	}
+	if b.remote {
+		return b.releaseManifest(b.chart)
+	}
+	return b.renderTemplate()
+}
 
-	cr := cv1.ResourceRequirements{
+func (b baseHelmCmd) Parse(manifest []byte) (*Requirements, error) {
+	switch b.scale {
+	case "", scaleCurrent, scaleMin, scaleMax:
+	case scaleAll:
+		return nil, fmt.Errorf("scale %s is supported only by sum", b.scale)
+	default:
+		return nil, fmt.Errorf("unknown scale %s", b.scale)
+	}
+	docs, err := splitManifest(manifest)
+	if err != nil {
+		return nil, err
+	}
+	docs = expandLists(docs)
+	b.autoscalers = collectAutoscalers(docs)
+	b.limitRanges = append(append([]cv1.LimitRange{}, b.limitRanges...), collectLimitRanges(docs, b.namespace)...)
+
+	cr := Requirements{}
+	cr.ResourceRequirements = cv1.ResourceRequirements{
 		Limits: cv1.ResourceList{
 			cv1.ResourceCPU:     resource.MustParse("0"),
 			cv1.ResourceMemory:  resource.MustParse("0"),
 			cv1.ResourceStorage: resource.MustParse("0"),
 
+			cv1.ResourceEphemeralStorage: resource.MustParse("0"),
+
 			cv1.ResourceConfigMaps:             resource.MustParse("0"),
 			cv1.ResourceSecrets:                resource.MustParse("0"),
 			cv1.ResourcePersistentVolumeClaims: resource.MustParse("0"),
 			cv1.ResourceServices:               resource.MustParse("0"),
 
-			jobCpu:    resource.MustParse("0"),
-			jobMemory: resource.MustParse("0"),
+			jobCpu:       resource.MustParse("0"),
+			jobMemory:    resource.MustParse("0"),
+			jobEphemeral: resource.MustParse("0"),
+
+			dsCpu:       resource.MustParse("0"),
+			dsMemory:    resource.MustParse("0"),
+			dsEphemeral: resource.MustParse("0"),
 		},
 		Requests: cv1.ResourceList{
 			cv1.ResourceCPU:     resource.MustParse("0"),
 			cv1.ResourceMemory:  resource.MustParse("0"),
 			cv1.ResourceStorage: resource.MustParse("0"),
 
-			jobCpu:     resource.MustParse("0"),
-			jobMemory:  resource.MustParse("0"),
-			jobStorage: resource.MustParse("0"),
+			cv1.ResourceEphemeralStorage: resource.MustParse("0"),
+
+			jobCpu:       resource.MustParse("0"),
+			jobMemory:    resource.MustParse("0"),
+			jobStorage:   resource.MustParse("0"),
+			jobEphemeral: resource.MustParse("0"),
+
+			dsCpu:       resource.MustParse("0"),
+			dsMemory:    resource.MustParse("0"),
+			dsEphemeral: resource.MustParse("0"),
 		},
 	}
 
+	if err := b.loadWorkloads(); err != nil {
+		return nil, err
+	}
+
 	parsers := []TypeParser{
+		b.customParser(b.workloads),
+
 		b.parseCronJob,
+		b.parseJob,
+		b.parseReplicationController,
 		b.parseDeployment,
+		b.parseReplicaSet,
+		b.parsePod,
 		b.parseStatefulset,
+		b.parseDaemonSet,
 
 		b.parseConfigmap,
 		b.parseSecret,
@@ -80,9 +129,7 @@ func (b baseHelmCmd) Parse(manifest []byte) (*cv1.ResourceRequirements, error) {
 		b.parseService,
 	}
 
-	for scanner.Scan() {
-		content := scanner.Bytes()
-
+	for _, content := range docs {
 		for _, p := range parsers {
 			if ok, err := p(content, &cr); err != nil {
 				return nil, err
@@ -93,22 +140,13 @@ func (b baseHelmCmd) Parse(manifest []byte) (*cv1.ResourceRequirements, error) {
 			}
 		}
 	}
-	if err := scanner.Err(); err != nil {
-		return nil, err
-	}
 	return &cr, nil
 }
 
 func (b baseHelmCmd) procRequirementSrc(resourceSrc cv1.ResourceName, resourceTgt cv1.ResourceName, pathid string, rr cv1.ResourceList, tgt cv1.ResourceList, repl int32, role string) error {
-	v := rr[resourceSrc]
-
-	if v.IsZero() {
-		if vp, err := b.defaultResource(pathid, resourceSrc, b.getDefault(resourceSrc, role), role); err != nil {
-			return err
-		} else {
-			v = *vp
-		}
-
+	v, err := b.requirementValue(resourceSrc, pathid, rr, role)
+	if err != nil {
+		return err
 	}
 
 	if t, ok := tgt[resourceTgt]; ok {
@@ -123,49 +161,37 @@ func (b baseHelmCmd) procRequirement(resource cv1.ResourceName, pathid string, r
 	return b.procRequirementSrc(resource, resource, pathid, rr, tgt, repl, role)
 }
 
-func (b baseHelmCmd) procResourceRequirements(pathid string, rr cv1.ResourceRequirements, tgt *cv1.ResourceRequirements, repl int32) error {
-	if err := b.procRequirement(cv1.ResourceCPU, pathid, rr.Limits, tgt.Limits, repl, "limit"); err != nil {
-		return err
-	}
-	if err := b.procRequirement(cv1.ResourceMemory, pathid, rr.Limits, tgt.Limits, repl, "limit"); err != nil {
-		return err
-	}
-	if err := b.procRequirement(cv1.ResourceCPU, pathid, rr.Requests, tgt.Requests, repl, "request"); err != nil {
-		return err
-	}
-	if err := b.procRequirement(cv1.ResourceMemory, pathid, rr.Requests, tgt.Requests, repl, "request"); err != nil {
-		return err
+// requirementValue returns requirement value or its default when not defined
+func (b baseHelmCmd) requirementValue(r cv1.ResourceName, pathid string, rr cv1.ResourceList, role string) (resource.Quantity, error) {
+	v := rr[r]
+
+	if v.IsZero() {
+		if vp, err := b.defaultResource(pathid, r, b.getDefault(r, role), role); err != nil {
+			return v, err
+		} else {
+			v = *vp
+		}
 	}
-	return nil
+	return v.DeepCopy(), nil
 }
 
 func (b baseHelmCmd) defaultResource(pathid string, typ cv1.ResourceName, val string, role string) (*resource.Quantity, error) {
-	if typ == "cpu" {
-		if val != "" {
-			v, err := resource.ParseQuantity(val)
-			return &v, err
-		} else {
-			if b.require {
-				return nil, fmt.Errorf("CPU %s not defined in %s", role, pathid)
-			} else {
-				return &ZERO, nil
-			}
-		}
-	} else {
-		if val != "" {
-			v, err := resource.ParseQuantity(val)
-			return &v, err
-		} else {
-			if b.require {
-				return nil, fmt.Errorf("Memory %s not defined in %s", role, pathid)
-			} else {
-				return &ZERO, nil
-			}
-		}
+	if val != "" {
+		v, err := resource.ParseQuantity(val)
+		return &v, err
+	}
+	switch {
+	case typ == cv1.ResourceCPU && b.require:
+		return nil, fmt.Errorf("CPU %s not defined in %s", role, pathid)
+	case typ == cv1.ResourceMemory && b.require:
+		return nil, fmt.Errorf("Memory %s not defined in %s", role, pathid)
+	case typ == cv1.ResourceEphemeralStorage && b.requireEphemeral:
+		return nil, fmt.Errorf("Ephemeral storage %s not defined in %s", role, pathid)
 	}
+	return &ZERO, nil
 }
 
-func (b baseHelmCmd) parseService(content []byte, cr *cv1.ResourceRequirements) (bool, error) {
+func (b baseHelmCmd) parseService(content []byte, cr *Requirements) (bool, error) {
 	depl := cv1.Service{}
 
 	err := yaml.Unmarshal(content, &depl)
@@ -181,7 +207,7 @@ func (b baseHelmCmd) parseService(content []byte, cr *cv1.ResourceRequirements)
 	return false, nil
 }
 
-func (b baseHelmCmd) parseConfigmap(content []byte, cr *cv1.ResourceRequirements) (bool, error) {
+func (b baseHelmCmd) parseConfigmap(content []byte, cr *Requirements) (bool, error) {
 	depl := cv1.ConfigMap{}
 
 	err := yaml.Unmarshal(content, &depl)
@@ -197,7 +223,7 @@ func (b baseHelmCmd) parseConfigmap(content []byte, cr *cv1.ResourceRequirements
 	return false, nil
 }
 
-func (b baseHelmCmd) parseSecret(content []byte, cr *cv1.ResourceRequirements) (bool, error) {
+func (b baseHelmCmd) parseSecret(content []byte, cr *Requirements) (bool, error) {
 	depl := cv1.Secret{}
 
 	err := yaml.Unmarshal(content, &depl)
@@ -213,7 +239,7 @@ func (b baseHelmCmd) parseSecret(content []byte, cr *cv1.ResourceRequirements) (
 	return false, nil
 }
 
-func (b baseHelmCmd) parsePvc(content []byte, cr *cv1.ResourceRequirements) (bool, error) {
+func (b baseHelmCmd) parsePvc(content []byte, cr *Requirements) (bool, error) {
 	depl := cv1.PersistentVolumeClaim{}
 
 	err := yaml.Unmarshal(content, &depl)
@@ -221,10 +247,7 @@ func (b baseHelmCmd) parsePvc(content []byte, cr *cv1.ResourceRequirements) (boo
 		return false, err
 	}
 	if depl.Kind == "PersistentVolumeClaim" {
-		t := cr.Limits[cv1.ResourcePersistentVolumeClaims]
-		t.Add(UNO)
-		cr.Limits[cv1.ResourcePersistentVolumeClaims] = t
-		if err := b.procRequirement(cv1.ResourceStorage, fmt.Sprintf("PVC: %s", depl.Name), depl.Spec.Resources.Requests, cr.Requests, 1, "request"); err != nil {
+		if err := b.procPvc(Claim{Kind: depl.Kind, Name: depl.Name, Replicas: 1}, depl.Spec, cr); err != nil {
 			return false, err
 		}
 		return true, nil
@@ -232,47 +255,86 @@ func (b baseHelmCmd) parsePvc(content []byte, cr *cv1.ResourceRequirements) (boo
 	return false, nil
 }
 
-func (b baseHelmCmd) parseDeployment(content []byte, cr *cv1.ResourceRequirements) (bool, error) {
+// procPvc accumulates claims count and storage of claim replicas, in total and per storage class
+func (b baseHelmCmd) procPvc(claim Claim, spec cv1.PersistentVolumeClaimSpec, cr *Requirements) error {
+	pathid := fmt.Sprintf("PVC: %s", claim.Name)
+	if claim.Template != "" {
+		pathid = fmt.Sprintf("%s: %s, VolumeClaimTemplate: %s", claim.Kind, claim.Name, claim.Template)
+	}
+	repl := claim.Replicas
+	class := b.defaultStorageClass
+	if spec.StorageClassName != nil {
+		class = *spec.StorageClassName
+	} else if class == "" && b.storageClass != nil {
+		class = b.storageClass()
+	}
+	sc := cv1.ResourceName(scPrefix + class)
+	if class != "" {
+		if _, ok := cr.Requests[sc]; !ok {
+			cr.Requests[sc] = resource.MustParse("0")
+			cr.Limits[sc] = resource.MustParse("0")
+		}
+	}
+
+	for _, r := range []cv1.ResourceName{cv1.ResourcePersistentVolumeClaims, sc} {
+		if t, ok := cr.Limits[r]; ok {
+			t.Add(*resource.NewQuantity(int64(repl), resource.DecimalSI))
+			cr.Limits[r] = t
+		}
+	}
+	cr.Violations = append(cr.Violations, b.claimViolations(pathid, spec)...)
+	claim.StorageClass = class
+	claim.Storage = spec.Resources.Requests[cv1.ResourceStorage].DeepCopy()
+	cr.Claims = append(cr.Claims, claim)
+	if err := b.procRequirement(cv1.ResourceStorage, pathid, spec.Resources.Requests, cr.Requests, repl, "request"); err != nil {
+		return err
+	}
+	return b.procRequirementSrc(cv1.ResourceStorage, sc, pathid, spec.Resources.Requests, cr.Requests, repl, "request")
+}
+
+func (b baseHelmCmd) parseDeployment(content []byte, cr *Requirements) (bool, error) {
 	depl := appsv1.Deployment{}
 
 	err := yaml.Unmarshal(content, &depl)
 	if err != nil {
 		// assume yaml is valid and error caused type incompatibility
-		fmt.Println(err)
+		fmt.Fprintln(os.Stderr, err)
 		return false, nil
 	}
 	if depl.Kind == "Deployment" {
-		repl := int32(1)
-		if depl.Spec.Replicas != nil {
-			repl = *depl.Spec.Replicas
-		}
+		repl := b.replicas(depl.APIVersion, depl.Kind, depl.Name, depl.Spec.Replicas)
 
-		for _, c := range depl.Spec.Template.Spec.Containers {
-			if err = b.procResourceRequirements(fmt.Sprintf("Deployment: %s, Container: %s", depl.Name, c.Name), c.Resources, cr, repl); err != nil {
-				return false, err
-			}
+		pathid := fmt.Sprintf("Deployment: %s", depl.Name)
+		if err = b.procPodSpec("", depl.Kind, depl.Name, depl.Spec.Template.Spec, cr, repl); err != nil {
+			return false, err
+		}
+		surge, err := deploymentSurge(depl, repl)
+		if err != nil {
+			return false, fmt.Errorf("%s: %w", pathid, err)
+		}
+		if err = b.procPodSpec(surgePrefix, depl.Kind, depl.Name, depl.Spec.Template.Spec, cr, surge); err != nil {
+			return false, err
 		}
 		return true, nil
 	}
 	return false, nil
 }
 
-func (b baseHelmCmd) parseStatefulset(content []byte, cr *cv1.ResourceRequirements) (bool, error) {
+func (b baseHelmCmd) parseStatefulset(content []byte, cr *Requirements) (bool, error) {
 	depl := appsv1.StatefulSet{}
 
 	err := yaml.Unmarshal(content, &depl)
 	if err != nil {
-		fmt.Println(err)
 		return false, err
 	}
 	if depl.Kind == "StatefulSet" {
-		repl := int32(1)
-		if depl.Spec.Replicas != nil {
-			repl = *depl.Spec.Replicas
-		}
+		repl := b.replicas(depl.APIVersion, depl.Kind, depl.Name, depl.Spec.Replicas)
 
-		for _, c := range depl.Spec.Template.Spec.Containers {
-			if err = b.procResourceRequirements(fmt.Sprintf("StatefulSet: %s, Container: %s", depl.Name, c.Name), c.Resources, cr, repl); err != nil {
+		if err = b.procPodSpec("", depl.Kind, depl.Name, depl.Spec.Template.Spec, cr, repl); err != nil {
+			return false, err
+		}
+		for _, vct := range depl.Spec.VolumeClaimTemplates {
+			if err = b.procPvc(Claim{Kind: depl.Kind, Name: depl.Name, Template: vct.Name, Replicas: repl}, vct.Spec, cr); err != nil {
 				return false, err
 			}
 		}
@@ -281,27 +343,124 @@ func (b baseHelmCmd) parseStatefulset(content []byte, cr *cv1.ResourceRequiremen
 	return false, nil
 }
 
-func (b baseHelmCmd) parseCronJob(content []byte, cr *cv1.ResourceRequirements) (bool, error) {
+func (b baseHelmCmd) parseDaemonSet(content []byte, cr *Requirements) (bool, error) {
+	depl := appsv1.DaemonSet{}
+
+	err := yaml.Unmarshal(content, &depl)
+	if err != nil {
+		return false, err
+	}
+	if depl.Kind == "DaemonSet" {
+		nodes, err := b.nodeCount(depl.Spec.Template.Spec.NodeSelector)
+		if err != nil {
+			return false, err
+		}
+
+		pathid := fmt.Sprintf("DaemonSet: %s", depl.Name)
+		if err = b.procPodSpec(dsPrefix, depl.Kind, depl.Name, depl.Spec.Template.Spec, cr, nodes); err != nil {
+			return false, err
+		}
+		surge, err := daemonSetSurge(depl, nodes)
+		if err != nil {
+			return false, fmt.Errorf("%s: %w", pathid, err)
+		}
+		if err = b.procPodSpec(surgePrefix, depl.Kind, depl.Name, depl.Spec.Template.Spec, cr, surge); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// nodeCount returns number of nodes DaemonSet pods are scheduled on. Cluster nodes are matched by node selector only,
+// node affinity and taints are not taken into account.
+func (b baseHelmCmd) nodeCount(selector map[string]string) (int32, error) {
+	if b.nodes > 0 {
+		return b.nodes, nil
+	}
+	if b.offline {
+		return 0, errors.New("--nodes is required to count DaemonSet pods without cluster access")
+	}
+	count := GetNodeCount
+	if b.nodeCounts != nil {
+		count = b.nodeCounts
+	}
+	nodes, err := count(selector)
+	if err != nil {
+		return 0, fmt.Errorf("could not count nodes DaemonSet pods run on, set --nodes: %w", err)
+	}
+	return nodes, nil
+}
+
+func (b baseHelmCmd) parseCronJob(content []byte, cr *Requirements) (bool, error) {
 	depl := bav1.CronJob{}
 
 	err := yaml.Unmarshal(content, &depl)
 	if err != nil {
-		fmt.Println(err)
 		return false, err
 	}
 	if depl.Kind == "CronJob" {
-		for _, c := range depl.Spec.JobTemplate.Spec.Template.Spec.Containers {
-			pathid := fmt.Sprintf("CronJob: %s, Container: %s", depl.Name, c.Name)
-			if err := b.procRequirementSrc(cv1.ResourceCPU, jobCpu, pathid, c.Resources.Limits, cr.Limits, 1, "limit"); err != nil {
-				return false, err
-			}
-			if err := b.procRequirementSrc(cv1.ResourceMemory, jobMemory, pathid, c.Resources.Limits, cr.Limits, 1, "limit"); err != nil {
-				return false, err
-			}
-			if err := b.procRequirementSrc(cv1.ResourceCPU, jobCpu, pathid, c.Resources.Requests, cr.Requests, 1, "request"); err != nil {
-				return false, err
-			}
-			if err := b.procRequirementSrc(cv1.ResourceMemory, jobMemory, pathid, c.Resources.Requests, cr.Requests, 1, "request"); err != nil {
+		repl := jobParallelism(depl.Spec.JobTemplate.Spec)
+		if depl.Spec.ConcurrencyPolicy == "" || depl.Spec.ConcurrencyPolicy == bav1.AllowConcurrent {
+			repl *= max(b.concurrentJobs, 1)
+		}
+		if err := b.procPodSpec(jobPrefix, depl.Kind, depl.Name, depl.Spec.JobTemplate.Spec.Template.Spec, cr, repl); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+func (b baseHelmCmd) parseJob(content []byte, cr *Requirements) (bool, error) {
+	depl := bav1.Job{}
+
+	err := yaml.Unmarshal(content, &depl)
+	if err != nil {
+		// assume yaml is valid and error caused type incompatibility
+		return false, nil
+	}
+	if depl.Kind == "Job" {
+		if err := b.procPodSpec(jobPrefix, depl.Kind, depl.Name, depl.Spec.Template.Spec, cr, jobParallelism(depl.Spec)); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+func (b baseHelmCmd) parseReplicaSet(content []byte, cr *Requirements) (bool, error) {
+	depl := appsv1.ReplicaSet{}
+
+	err := yaml.Unmarshal(content, &depl)
+	if err != nil {
+		// assume yaml is valid and error caused type incompatibility
+		return false, nil
+	}
+	if depl.Kind == "ReplicaSet" {
+		repl := b.replicas(depl.APIVersion, depl.Kind, depl.Name, depl.Spec.Replicas)
+
+		if err = b.procPodSpec("", depl.Kind, depl.Name, depl.Spec.Template.Spec, cr, repl); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+func (b baseHelmCmd) parseReplicationController(content []byte, cr *Requirements) (bool, error) {
+	depl := cv1.ReplicationController{}
+
+	err := yaml.Unmarshal(content, &depl)
+	if err != nil {
+		// assume yaml is valid and error caused type incompatibility
+		return false, nil
+	}
+	if depl.Kind == "ReplicationController" {
+		repl := b.replicas(depl.APIVersion, depl.Kind, depl.Name, depl.Spec.Replicas)
+
+		if depl.Spec.Template != nil {
+			if err = b.procPodSpec("", depl.Kind, depl.Name, depl.Spec.Template.Spec, cr, repl); err != nil {
 				return false, err
 			}
 		}
@@ -309,3 +468,32 @@ func (b baseHelmCmd) parseCronJob(content []byte, cr *cv1.ResourceRequirements)
 	}
 	return false, nil
 }
+
+func (b baseHelmCmd) parsePod(content []byte, cr *Requirements) (bool, error) {
+	depl := cv1.Pod{}
+
+	err := yaml.Unmarshal(content, &depl)
+	if err != nil {
+		// assume yaml is valid and error caused type incompatibility
+		return false, nil
+	}
+	if depl.Kind == "Pod" {
+		if err = b.procPodSpec("", depl.Kind, depl.Name, depl.Spec, cr, 1); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// jobParallelism returns maximum number of pods job runs at once
+func jobParallelism(spec bav1.JobSpec) int32 {
+	p := int32(1)
+	if spec.Parallelism != nil {
+		p = *spec.Parallelism
+	}
+	if spec.Completions != nil && *spec.Completions < p {
+		p = *spec.Completions
+	}
+	return p
+}
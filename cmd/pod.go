@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	cv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+var podResources = []cv1.ResourceName{cv1.ResourceCPU, cv1.ResourceMemory, cv1.ResourceEphemeralStorage}
+
+// isExtendedResource reports whether resource is an extended (domain prefixed) or hugepages resource
+func isExtendedResource(r cv1.ResourceName) bool {
+	return strings.Contains(string(r), "/") || strings.HasPrefix(string(r), cv1.ResourceHugePagesPrefix)
+}
+
+// specResources returns compute resources and all extended resources used by pod spec
+func specResources(spec cv1.PodSpec) []cv1.ResourceName {
+	names := append([]cv1.ResourceName{}, podResources...)
+	seen := map[cv1.ResourceName]bool{}
+	for _, c := range append(append([]cv1.Container{}, spec.InitContainers...), spec.Containers...) {
+		for _, rl := range []cv1.ResourceList{c.Resources.Limits, c.Resources.Requests} {
+			for r := range rl {
+				if isExtendedResource(r) && !seen[r] {
+					seen[r] = true
+					names = append(names, r)
+				}
+			}
+		}
+	}
+	return names
+}
+
+// procPodSpec accumulates effective pod requirements multiplied by repl into resources prefixed with bucket
+func (b baseHelmCmd) procPodSpec(bucket string, kind string, name string, spec cv1.PodSpec, tgt *Requirements, repl int32) error {
+	pathid := fmt.Sprintf("%s: %s", kind, name)
+	pod, containers, err := b.podRequirements(pathid, spec)
+	if err != nil {
+		return err
+	}
+	addPod(bucket, *pod, &tgt.ResourceRequirements, repl)
+	if bucket != surgePrefix {
+		// surge pods share template with workload pods already validated
+		tgt.Violations = append(tgt.Violations, b.podViolations(pathid, spec, pod)...)
+	}
+
+	terminating := spec.ActiveDeadlineSeconds != nil && *spec.ActiveDeadlineSeconds >= 0
+	tgt.Workloads = append(tgt.Workloads, Workload{
+		Kind:     kind,
+		Name:     name,
+		Bucket:   bucket,
+		Replicas: repl,
+		Pod:      *pod,
+
+		Containers: containers,
+
+		BestEffort:             isBestEffort(pod),
+		Terminating:            terminating,
+		PriorityClass:          spec.PriorityClassName,
+		CrossNamespaceAffinity: hasCrossNamespaceAffinity(spec.Affinity),
+	})
+	return nil
+}
+
+// podRequirements computes effective pod requirements the way scheduler and quota admission do:
+// the bigger of app containers plus sidecars and of any init container plus sidecars started before it,
+// increased by pod overhead. Requirements of each container are returned as well.
+func (b baseHelmCmd) podRequirements(pathid string, spec cv1.PodSpec) (*cv1.ResourceRequirements, []ContainerRequirements, error) {
+	pod := cv1.ResourceRequirements{
+		Limits:   cv1.ResourceList{},
+		Requests: cv1.ResourceList{},
+	}
+	containers := []ContainerRequirements{}
+	for _, c := range spec.InitContainers {
+		containers = append(containers, ContainerRequirements{Name: c.Name, Init: true})
+	}
+	for _, c := range spec.Containers {
+		containers = append(containers, ContainerRequirements{Name: c.Name})
+	}
+	for i := range containers {
+		containers[i].Resources = cv1.ResourceRequirements{Limits: cv1.ResourceList{}, Requests: cv1.ResourceList{}}
+	}
+	for _, role := range []string{"limit", "request"} {
+		tgt := pod.Requests
+		if role == "limit" {
+			tgt = pod.Limits
+		}
+		container := func(i int) cv1.ResourceList {
+			if role == "limit" {
+				return containers[i].Resources.Limits
+			}
+			return containers[i].Resources.Requests
+		}
+		for _, r := range specResources(spec) {
+			value := func(c cv1.Container, ctype string) (resource.Quantity, error) {
+				res := b.containerResources(c)
+				rr := res.Requests
+				if role == "limit" {
+					rr = res.Limits
+				}
+				return b.requirementValue(r, fmt.Sprintf("%s, %s: %s", pathid, ctype, c.Name), rr, role)
+			}
+
+			sum := resource.MustParse("0")
+			for i, c := range spec.Containers {
+				v, err := value(c, "Container")
+				if err != nil {
+					return nil, nil, err
+				}
+				container(len(spec.InitContainers) + i)[r] = v.DeepCopy()
+				sum.Add(v)
+			}
+
+			sidecars := resource.MustParse("0")
+			init := resource.MustParse("0")
+			for i, c := range spec.InitContainers {
+				v, err := value(c, "InitContainer")
+				if err != nil {
+					return nil, nil, err
+				}
+				container(i)[r] = v.DeepCopy()
+				if c.RestartPolicy != nil && *c.RestartPolicy == cv1.ContainerRestartPolicyAlways {
+					sidecars.Add(v)
+					v = sidecars.DeepCopy()
+				} else {
+					v.Add(sidecars)
+				}
+				if v.Cmp(init) > 0 {
+					init = v
+				}
+			}
+
+			sum.Add(sidecars)
+			if init.Cmp(sum) > 0 {
+				sum = init
+			}
+			if o, ok := spec.Overhead[r]; ok {
+				sum.Add(o)
+			}
+			tgt[r] = sum
+		}
+	}
+	return &pod, containers, nil
+}
@@ -2,44 +2,209 @@ package cmd
 
 import (
 	"context"
-	"flag"
+	"encoding/json"
 	"fmt"
-	"path/filepath"
+	"io"
+	"os"
+	"strings"
 
 	cv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/clientcmd"
-	"k8s.io/client-go/util/homedir"
+	"sigs.k8s.io/yaml"
 )
 
-func GetQuota(namespace string) (*cv1.ResourceQuota, error) {
-	var kubeconfig *string
-	if home := homedir.HomeDir(); home != "" {
-		kubeconfig = flag.String("kubeconfig", filepath.Join(home, ".kube", "config"), "(optional) absolute path to the kubeconfig file")
-	} else {
-		kubeconfig = flag.String("kubeconfig", "", "absolute path to the kubeconfig file")
-	}
-	flag.Parse()
+// quotaLimit is hard limit of a resource defined by single resource quota
+type quotaLimit struct {
+	owner string
+	hard  resource.Quantity
+	used  resource.Quantity
+	// scope filters workloads charged by the quota, nil if quota applies to everything
+	scope func(Workload) bool
+}
+
+// Quota is hard limits of all namespace resource quotas
+type Quota struct {
+	limits map[cv1.ResourceName][]quotaLimit
+}
+
+// QuotaStatus is evaluation of a resource against the most restrictive resource quota
+type QuotaStatus struct {
+	Owner     string
+	Hard      *resource.Quantity
+	Used      resource.Quantity
+	Available *resource.Quantity
 
-	config, err := clientcmd.BuildConfigFromFlags("", *kubeconfig)
+	Static  bool
+	Sum     bool
+	Rollout bool
+}
+
+func GetQuota(namespace string) (*Quota, error) {
+	quotas, err := listQuotas(namespace)
 	if err != nil {
 		return nil, err
 	}
-	clientset, err := kubernetes.NewForConfig(config)
+	if len(quotas) == 0 {
+		return nil, fmt.Errorf("no resource quotas defined in namespace %s", namespace)
+	}
+	return MergeQuotas(quotas), nil
+}
+
+// listQuotas returns resource quotas defined in namespace
+func listQuotas(namespace string) ([]cv1.ResourceQuota, error) {
+	clientset, err := newClientset()
 	if err != nil {
 		return nil, err
 	}
-	pc := clientset.CoreV1().ResourceQuotas(namespace)
-	rql, err := pc.List(context.TODO(), v1.ListOptions{})
+	rql, err := clientset.CoreV1().ResourceQuotas(namespace).List(context.TODO(), v1.ListOptions{})
 	if err != nil {
 		return nil, err
 	}
-	if len(rql.Items) == 0 {
-		return nil, fmt.Errorf("no resource quotas defined in namespace %s", namespace)
+	return rql.Items, nil
+}
+
+// LoadQuotaFiles reads ResourceQuotas and LimitRanges applying to namespace from manifest files, "-" reads stdin.
+// Quotas without status are limited by their spec and have no usage.
+func LoadQuotaFiles(paths []string, namespace string) (*Quota, []cv1.LimitRange, error) {
+	docs := [][]byte{}
+	for _, path := range paths {
+		var data []byte
+		var err error
+		if path == "-" {
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(path)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		d, err := splitManifest(data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", path, err)
+		}
+		docs = append(docs, expandLists(d)...)
 	}
-	if len(rql.Items) > 1 {
-		return nil, fmt.Errorf("%d resource quotas defined in namespace %s", len(rql.Items), namespace)
+
+	quotas := []cv1.ResourceQuota{}
+	for _, content := range docs {
+		rq := cv1.ResourceQuota{}
+		if err := yaml.Unmarshal(content, &rq); err != nil {
+			// assume yaml is valid and error caused type incompatibility
+			continue
+		}
+		if rq.Kind != "ResourceQuota" || (rq.Namespace != "" && namespace != "" && rq.Namespace != namespace) {
+			continue
+		}
+		if len(rq.Status.Hard) == 0 {
+			rq.Status.Hard = rq.Spec.Hard
+		}
+		quotas = append(quotas, rq)
+	}
+	if len(quotas) == 0 {
+		return nil, nil, fmt.Errorf("no resource quotas defined in %s", strings.Join(paths, ", "))
+	}
+	return MergeQuotas(quotas), collectLimitRanges(docs, namespace), nil
+}
+
+// expandLists replaces List documents (e.g. kubectl get -o yaml output) with their items
+func expandLists(docs [][]byte) [][]byte {
+	res := [][]byte{}
+	for _, content := range docs {
+		l := struct {
+			Kind  string            `json:"kind"`
+			Items []json.RawMessage `json:"items"`
+		}{}
+		if err := yaml.Unmarshal(content, &l); err != nil || !strings.HasSuffix(l.Kind, "List") {
+			res = append(res, content)
+			continue
+		}
+		for _, item := range l.Items {
+			res = append(res, item)
+		}
+	}
+	return res
+}
+
+// MergeQuotas collects hard limits of resource quotas, each resource is checked against all quotas limiting it
+func MergeQuotas(quotas []cv1.ResourceQuota) *Quota {
+	q := Quota{
+		limits: map[cv1.ResourceName][]quotaLimit{},
+	}
+	for _, rq := range quotas {
+		scope := quotaScope(rq)
+		for r, v := range rq.Status.Hard {
+			qr := quotaResource(r)
+			q.limits[qr] = append(q.limits[qr], quotaLimit{
+				owner: rq.Name,
+				hard:  v,
+				used:  rq.Status.Used[r],
+				scope: scope,
+			})
+		}
+	}
+	return &q
+}
+
+// quotaResource maps short quota resource names to their requests equivalents
+func quotaResource(r cv1.ResourceName) cv1.ResourceName {
+	switch r {
+	case cv1.ResourceCPU, cv1.ResourceMemory, cv1.ResourceEphemeralStorage:
+		return cv1.ResourceName(cv1.DefaultResourceRequestsPrefix + string(r))
+	}
+	return r
+}
+
+// available returns capacity left: hard limit minus usage not caused by own release footprint
+func (l quotaLimit) available(own resource.Quantity) resource.Quantity {
+	used := l.used.DeepCopy()
+	used.Sub(own)
+	if used.Sign() < 0 {
+		used = resource.Quantity{}
+	}
+	available := l.hard.DeepCopy()
+	available.Sub(used)
+	return available
+}
+
+// Check evaluates row resource against every quota limiting it. Scoped quotas are charged only by matching workloads,
+// own is footprint of release being upgraded which is already part of quota usage.
+// Status describes quota leaving the smallest headroom, resources without limit always fit.
+func (q Quota) Check(row resourceRow, req *Requirements, own *Requirements) QuotaStatus {
+	st := QuotaStatus{Static: true, Sum: true, Rollout: true}
+	var margin *resource.Quantity
+	for _, l := range q.limits[row.quota] {
+		r, o := req, own
+		if l.scope != nil {
+			r = &Requirements{ResourceRequirements: *req.Pods(l.scope)}
+			o = &Requirements{ResourceRequirements: *own.Pods(l.scope)}
+		}
+		rl := row.list(r)
+		static, _, _, sum := totals(rl, row.resource)
+		ro := rollout(rl, row.resource)
+		available := l.available(running(row.list(o), row.resource))
+
+		st.Static = st.Static && static.Cmp(available) <= 0
+		st.Sum = st.Sum && sum.Cmp(available) <= 0
+		st.Rollout = st.Rollout && ro.Cmp(available) <= 0
+
+		m := available.DeepCopy()
+		m.Sub(sum)
+		if margin == nil || m.Cmp(*margin) < 0 {
+			margin = &m
+			st.Owner = l.owner
+			st.Hard = &l.hard
+			st.Used = l.used
+			st.Available = &available
+		}
+	}
+	return st
+}
+
+// printQuantity returns printable quantity, none if it is not defined
+func printQuantity(v *resource.Quantity) string {
+	if v == nil {
+		return "none"
 	}
-	return &rql.Items[0], nil
+	return v.String()
 }
@@ -0,0 +1,346 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	cv1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+type releasesCmd struct {
+	baseHelmCmd
+	allNamespaces bool
+	output        string
+}
+
+// deployedRelease is release found in cluster along with its manifest
+type deployedRelease struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Chart     string `json:"chart"`
+	Manifest  []byte `json:"-"`
+}
+
+// namespaceReleases is requirements of releases deployed to namespace, quota is nil when namespace has none
+type namespaceReleases struct {
+	namespace    string
+	releases     []deployedRelease
+	requirements []*Requirements
+	limitRanges  []cv1.LimitRange
+	quota        *Quota
+	// quotaError tells why quotas or LimitRanges of namespace could not be read
+	quotaError string
+	// failed are releases whose requirements could not be evaluated, they are not counted in namespace total
+	failed []failedRelease
+}
+
+// failedRelease is release whose manifest could not be parsed, e.g. when nodes of its DaemonSets can not be listed
+type failedRelease struct {
+	deployedRelease
+	err string
+}
+
+// ReleasesReport is versioned output of deployed releases footprint per namespace
+type ReleasesReport struct {
+	APIVersion string            `json:"apiVersion"`
+	Kind       string            `json:"kind"`
+	Scale      string            `json:"scale,omitempty"`
+	Namespaces []NamespaceReport `json:"namespaces"`
+}
+
+// NamespaceReport is footprint of every release in namespace, total has quota fields when namespace has quotas
+type NamespaceReport struct {
+	Namespace  string           `json:"namespace"`
+	Releases   []ReleaseReport  `json:"releases"`
+	Total      []ResourceReport `json:"total"`
+	QuotaError string           `json:"quotaError,omitempty"`
+}
+
+// ReleaseReport is footprint of single release, resources have share of namespace quota when namespace has quotas.
+// Error tells why requirements of release could not be evaluated, resources are empty then.
+type ReleaseReport struct {
+	Name      string           `json:"name"`
+	Chart     string           `json:"chart,omitempty"`
+	Resources []ResourceReport `json:"resources"`
+	Error     string           `json:"error,omitempty"`
+}
+
+func newReleasesCommand() *cobra.Command {
+	releases := releasesCmd{}
+
+	cmd := &cobra.Command{
+		Use:   "releases",
+		Short: "Show resource requirements of every release deployed to namespace or cluster",
+		Long:  rootCmdLongUsage,
+		Args:  cobra.NoArgs,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return validateOutput(releases.output, outputTable, outputJSON, outputYAML)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			return releases.run()
+		},
+	}
+	releases.propogateCmdFlags(cmd)
+	f := cmd.Flags()
+	f.BoolVarP(&releases.allNamespaces, "all-namespaces", "A", false, "Analyze releases of all namespaces")
+	f.StringVar(&releases.output, "output", "", "Output format: table (default), json or yaml")
+	return cmd
+}
+
+func (r releasesCmd) run() error {
+	if err := r.loadWorkloads(); err != nil {
+		return err
+	}
+	rels, err := r.listReleases(r.allNamespaces)
+	if err != nil {
+		return err
+	}
+	if r.defaultStorageClass == "" {
+		r.storageClass = discoverDefaultStorageClass()
+	}
+	r.nodeCounts = cachedNodeCount(GetNodeCount)
+	namespaces, err := r.namespaces(rels, func(nr *namespaceReleases) error {
+		quotas, err := listQuotas(nr.namespace)
+		if err != nil {
+			return nr.forbidden(err)
+		}
+		if len(quotas) > 0 {
+			nr.quota = MergeQuotas(quotas)
+		}
+		if nr.limitRanges, err = GetLimitRanges(nr.namespace); err != nil {
+			return nr.forbidden(err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return r.FormatOutput(os.Stdout, namespaces)
+}
+
+// namespaces groups releases by namespace and parses them, load fills quota and LimitRanges of namespace.
+// Releases failing to parse are recorded with their error so one release does not abort the whole report.
+func (r releasesCmd) namespaces(rels []deployedRelease, load func(*namespaceReleases) error) ([]*namespaceReleases, error) {
+	byName := map[string]*namespaceReleases{}
+	res := []*namespaceReleases{}
+	for _, rel := range rels {
+		nr, ok := byName[rel.Namespace]
+		if !ok {
+			nr = &namespaceReleases{namespace: rel.Namespace}
+			if err := load(nr); err != nil {
+				return nil, err
+			}
+			byName[rel.Namespace] = nr
+			res = append(res, nr)
+		}
+		b := r.baseHelmCmd
+		b.namespace = rel.Namespace
+		b.limitRanges = nr.limitRanges
+		req, err := b.Parse(rel.Manifest)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: release %s/%s is reported without requirements: %v\n", rel.Namespace, rel.Name, err)
+			nr.failed = append(nr.failed, failedRelease{rel, err.Error()})
+			continue
+		}
+		nr.releases = append(nr.releases, rel)
+		nr.requirements = append(nr.requirements, req)
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].namespace < res[j].namespace
+	})
+	return res, nil
+}
+
+// forbidden tolerates namespace the user can not read quotas or LimitRanges of, it is reported without quota data
+func (nr *namespaceReleases) forbidden(err error) error {
+	if !apierrors.IsForbidden(err) {
+		return err
+	}
+	nr.quota = nil
+	nr.quotaError = err.Error()
+	fmt.Fprintf(os.Stderr, "Warning: namespace %s is reported without quota: %v\n", nr.namespace, err)
+	return nil
+}
+
+// quotaShare returns percentage of the namespace quota hard limit the value takes, nil if resource is not limited
+func quotaShare(value resource.Quantity, st QuotaStatus) *float64 {
+	if st.Hard == nil || st.Hard.IsZero() {
+		return nil
+	}
+	share := math.Round(value.AsApproximateFloat64()/st.Hard.AsApproximateFloat64()*1000) / 10
+	return &share
+}
+
+// printShare returns printable quota share, none if resource is not limited
+func printShare(share *float64) string {
+	if share == nil {
+		return "none"
+	}
+	return fmt.Sprintf("%.1f%%", *share)
+}
+
+// total sums requirements of all releases in namespace
+func (nr namespaceReleases) total() *Requirements {
+	total := Requirements{}
+	for _, req := range nr.requirements {
+		total.Add(req)
+	}
+	return &total
+}
+
+// report builds machine readable footprint of releases, namespace total is checked against quotas with
+// releases already accounted in quota usage
+func (r releasesCmd) report(namespaces []*namespaceReleases) ReleasesReport {
+	report := ReleasesReport{
+		APIVersion: reportAPIVersion,
+		Kind:       "Releases",
+		Scale:      r.scale,
+		Namespaces: []NamespaceReport{},
+	}
+	for _, nr := range namespaces {
+		total := nr.total()
+		ns := NamespaceReport{
+			Namespace:  nr.namespace,
+			Releases:   []ReleaseReport{},
+			Total:      newReport("", r.scale, total).Resources,
+			QuotaError: nr.quotaError,
+		}
+		statuses := map[string]QuotaStatus{}
+		if nr.quota != nil {
+			for i, row := range append(resourceRows(total), countRows(total)...) {
+				st := nr.quota.Check(row, total, total)
+				ns.Total[i].setQuota(st)
+				statuses[string(row.quota)] = st
+			}
+		}
+		for i, rel := range nr.releases {
+			rr := ReleaseReport{
+				Name:      rel.Name,
+				Chart:     rel.Chart,
+				Resources: newReport("", r.scale, nr.requirements[i]).Resources,
+			}
+			for j, res := range rr.Resources {
+				if st, ok := statuses[res.Resource]; ok {
+					rr.Resources[j].QuotaShare = quotaShare(res.Sum, st)
+				}
+			}
+			ns.Releases = append(ns.Releases, rr)
+		}
+		for _, f := range nr.failed {
+			ns.Releases = append(ns.Releases, ReleaseReport{
+				Name:      f.Name,
+				Chart:     f.Chart,
+				Resources: []ResourceReport{},
+				Error:     f.err,
+			})
+		}
+		report.Namespaces = append(report.Namespaces, ns)
+	}
+	return report
+}
+
+func (r releasesCmd) FormatOutput(w io.Writer, namespaces []*namespaceReleases) error {
+	if isStructuredOutput(r.output) {
+		return writeReport(w, r.output, r.report(namespaces))
+	}
+
+	for _, nr := range namespaces {
+		total := nr.total()
+		rows := append(resourceRows(total), countRows(total)...)
+		line := func() error {
+			if _, err := fmt.Fprint(w, "+------------------------------+"+strings.Repeat("---------------+", len(rows))+"\n"); err != nil {
+				return err
+			}
+			return nil
+		}
+		values := func(req *Requirements) string {
+			sb := strings.Builder{}
+			for _, row := range rows {
+				_, _, _, sum := totals(row.list(req), row.resource)
+				fmt.Fprintf(&sb, " %13v |", &sum)
+			}
+			return sb.String()
+		}
+
+		if _, err := fmt.Fprintf(w, "Namespace: %s\n", nr.namespace); err != nil {
+			return err
+		}
+		if err := line(); err != nil {
+			return err
+		}
+		header := strings.Builder{}
+		for _, row := range rows {
+			fmt.Fprintf(&header, " %-13.13s |", row.title)
+		}
+		if _, err := fmt.Fprintf(w, "| Release                      |%s\n", header.String()); err != nil {
+			return err
+		}
+		if err := line(); err != nil {
+			return err
+		}
+		for i, rel := range nr.releases {
+			if _, err := fmt.Fprintf(w, "|%-30.30s|%s\n", rel.Name, values(nr.requirements[i])); err != nil {
+				return err
+			}
+		}
+		if err := line(); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "|%-30.30s|%s\n", "Total", values(total)); err != nil {
+			return err
+		}
+		if nr.quota != nil {
+			hard, used := strings.Builder{}, strings.Builder{}
+			for _, row := range rows {
+				st := nr.quota.Check(row, total, total)
+				fmt.Fprintf(&hard, " %13s |", printQuantity(st.Hard))
+				if st.Hard == nil {
+					fmt.Fprintf(&used, " %13s |", printQuantity(nil))
+				} else {
+					fmt.Fprintf(&used, " %13v |", &st.Used)
+				}
+			}
+			if _, err := fmt.Fprintf(w, "|%-30.30s|%s\n|%-30.30s|%s\n", "Quota", hard.String(), "Quota used", used.String()); err != nil {
+				return err
+			}
+			if err := line(); err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintf(w, "|%-30.30s|%s\n", "Share of quota", strings.Repeat("               |", len(rows))); err != nil {
+				return err
+			}
+			for i, rel := range nr.releases {
+				shares := strings.Builder{}
+				for _, row := range rows {
+					_, _, _, sum := totals(row.list(nr.requirements[i]), row.resource)
+					fmt.Fprintf(&shares, " %13s |", printShare(quotaShare(sum, nr.quota.Check(row, total, total))))
+				}
+				if _, err := fmt.Fprintf(w, "|%-30.30s|%s\n", rel.Name, shares.String()); err != nil {
+					return err
+				}
+			}
+		}
+		if err := line(); err != nil {
+			return err
+		}
+		if nr.quotaError != "" {
+			if _, err := fmt.Fprintf(w, "Quota not available: %s\n", nr.quotaError); err != nil {
+				return err
+			}
+		}
+		for _, f := range nr.failed {
+			if _, err := fmt.Fprintf(w, "Release %s not evaluated: %s\n", f.Name, f.err); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	reportAPIVersion = "helm-resource/v1"
+
+	outputText  = "text"
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+)
+
+// Report is versioned machine readable output of sum and check
+type Report struct {
+	APIVersion string           `json:"apiVersion"`
+	Kind       string           `json:"kind"`
+	Scale      string           `json:"scale,omitempty"`
+	Resources  []ResourceReport `json:"resources"`
+	Violations []string         `json:"violations,omitempty"`
+}
+
+// ReportList is output of sum evaluated for every scale
+type ReportList struct {
+	APIVersion string   `json:"apiVersion"`
+	Kind       string   `json:"kind"`
+	Items      []Report `json:"items"`
+}
+
+// ResourceReport is requirement of single resource named as in ResourceQuota, quota fields are filled by check and releases only.
+// Object counts have no static, daemonSets and jobs parts.
+type ResourceReport struct {
+	Resource   string             `json:"resource"`
+	Static     *resource.Quantity `json:"static,omitempty"`
+	DaemonSets *resource.Quantity `json:"daemonSets,omitempty"`
+	Jobs       *resource.Quantity `json:"jobs,omitempty"`
+	Sum        resource.Quantity  `json:"sum"`
+	Rollout    resource.Quantity  `json:"rollout"`
+
+	Quota       *resource.Quantity `json:"quota,omitempty"`
+	QuotaObject string             `json:"quotaObject,omitempty"`
+	Used        *resource.Quantity `json:"used,omitempty"`
+	Remaining   *resource.Quantity `json:"remaining,omitempty"`
+	StaticOK    *bool              `json:"staticOk,omitempty"`
+	OK          *bool              `json:"ok,omitempty"`
+	RolloutOK   *bool              `json:"rolloutOk,omitempty"`
+	// QuotaShare is percentage of namespace quota hard limit taken by release, filled by releases only
+	QuotaShare *float64 `json:"quotaShare,omitempty"`
+}
+
+func isStructuredOutput(output string) bool {
+	return output == outputJSON || output == outputYAML
+}
+
+// validateOutput rejects output format command does not support, empty one selects default format
+func validateOutput(output string, allowed ...string) error {
+	if output == "" || slices.Contains(allowed, output) {
+		return nil
+	}
+	return fmt.Errorf("unknown output format %s, allowed: %s", output, strings.Join(allowed, ", "))
+}
+
+// newReport builds report of compute, storage and object count resources
+func newReport(kind string, scale string, req *Requirements) Report {
+	report := Report{
+		APIVersion: reportAPIVersion,
+		Kind:       kind,
+		Scale:      scale,
+		Resources:  []ResourceReport{},
+		Violations: req.Violations,
+	}
+	for _, r := range resourceRows(req) {
+		rl := r.list(req)
+		static, ds, job, sum := totals(rl, r.resource)
+		report.Resources = append(report.Resources, ResourceReport{
+			Resource:   string(r.quota),
+			Static:     &static,
+			DaemonSets: &ds,
+			Jobs:       &job,
+			Sum:        sum,
+			Rollout:    rollout(rl, r.resource),
+		})
+	}
+	for _, r := range countRows(req) {
+		count := req.Limits[r.resource]
+		report.Resources = append(report.Resources, ResourceReport{
+			Resource: string(r.quota),
+			Sum:      count,
+			Rollout:  count,
+		})
+	}
+	return report
+}
+
+// setQuota fills quota evaluation of resource
+func (rr *ResourceReport) setQuota(st QuotaStatus) {
+	used := st.Used
+	rr.Quota = st.Hard
+	rr.QuotaObject = st.Owner
+	rr.Used = &used
+	rr.Remaining = st.Available
+	if rr.Static != nil {
+		rr.StaticOK = &st.Static
+	}
+	rr.OK = &st.Sum
+	rr.RolloutOK = &st.Rollout
+}
+
+// writeReport writes report in json or yaml format
+func writeReport(w io.Writer, output string, report interface{}) error {
+	var data []byte
+	var err error
+	switch output {
+	case outputJSON:
+		data, err = json.MarshalIndent(report, "", "  ")
+		data = append(data, '\n')
+	case outputYAML:
+		data, err = yaml.Marshal(report)
+	default:
+		return fmt.Errorf("unknown output format %s", output)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
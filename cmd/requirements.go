@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	cv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// Workload is contribution of a single pod template to requirements
+type Workload struct {
+	Kind     string
+	Name     string
+	Bucket   string
+	Replicas int32
+	// Pod is effective requirements of single pod
+	Pod cv1.ResourceRequirements
+	// Containers are requirements of pod containers, init containers first
+	Containers []ContainerRequirements
+
+	BestEffort             bool
+	Terminating            bool
+	PriorityClass          string
+	CrossNamespaceAffinity bool
+}
+
+// ContainerRequirements is requirements of single container with defaults applied
+type ContainerRequirements struct {
+	Name      string
+	Init      bool
+	Resources cv1.ResourceRequirements
+}
+
+// Claim is storage claimed by PersistentVolumeClaim or by StatefulSet volume claim template for each replica
+type Claim struct {
+	Kind     string
+	Name     string
+	Template string
+	Replicas int32
+	// StorageClass is class claimed, default class applied
+	StorageClass string
+	// Storage is request of single claim
+	Storage resource.Quantity
+}
+
+// Requirements is summary of manifest requirements along with workloads contributing to it
+type Requirements struct {
+	cv1.ResourceRequirements
+	Workloads []Workload
+	Claims    []Claim
+	// Violations are LimitRange constraints manifest objects break
+	Violations []string
+}
+
+// Pods sums requirements of workloads accepted by match into bucket prefixed resources
+func (req Requirements) Pods(match func(Workload) bool) *cv1.ResourceRequirements {
+	res := cv1.ResourceRequirements{
+		Limits:   cv1.ResourceList{},
+		Requests: cv1.ResourceList{},
+	}
+	for _, w := range req.Workloads {
+		if match(w) {
+			addPod(w.Bucket, w.Pod, &res, w.Replicas)
+		}
+	}
+	return &res
+}
+
+// Add accumulates requirements of another manifest, e.g. of another release deployed to the same namespace
+func (req *Requirements) Add(o *Requirements) {
+	add := func(dst *cv1.ResourceList, src cv1.ResourceList) {
+		if *dst == nil {
+			*dst = cv1.ResourceList{}
+		}
+		for r, v := range src {
+			t := (*dst)[r]
+			t.Add(v)
+			(*dst)[r] = t
+		}
+	}
+	add(&req.Limits, o.Limits)
+	add(&req.Requests, o.Requests)
+	req.Workloads = append(req.Workloads, o.Workloads...)
+	req.Claims = append(req.Claims, o.Claims...)
+	req.Violations = append(req.Violations, o.Violations...)
+}
+
+// addPod accumulates pod requirements multiplied by repl into resources prefixed with bucket
+func addPod(bucket string, pod cv1.ResourceRequirements, tgt *cv1.ResourceRequirements, repl int32) {
+	add := func(src cv1.ResourceList, dst cv1.ResourceList) {
+		for r, v := range src {
+			rt := cv1.ResourceName(bucket + string(r))
+			t := dst[rt]
+			v = v.DeepCopy()
+			v.Mul(int64(repl))
+			t.Add(v)
+			dst[rt] = t
+		}
+	}
+	add(pod.Limits, tgt.Limits)
+	add(pod.Requests, tgt.Requests)
+}
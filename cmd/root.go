@@ -9,6 +9,9 @@ const rootCmdLongUsage = `resource`
 func New() *cobra.Command {
 	sumCommand := newSumCommand()
 	checkCommand := newCheckCommand()
+	breakdownCommand := newBreakdownCommand()
+	diffCommand := newDiffCommand()
+	releasesCommand := newReleasesCommand()
 
 	cmd := &cobra.Command{
 		Use:   "resource",
@@ -20,7 +23,10 @@ func New() *cobra.Command {
 	// add flagset from chartCommand
 	cmd.Flags().AddFlagSet(sumCommand.Flags())
 	cmd.Flags().AddFlagSet(checkCommand.Flags())
-	cmd.AddCommand(versionCmd(), sumCommand, checkCommand)
+	cmd.Flags().AddFlagSet(breakdownCommand.Flags())
+	cmd.Flags().AddFlagSet(diffCommand.Flags())
+	cmd.Flags().AddFlagSet(releasesCommand.Flags())
+	cmd.AddCommand(versionCmd(), sumCommand, checkCommand, breakdownCommand, diffCommand, releasesCommand)
 	cmd.SetHelpCommand(&cobra.Command{})
 	return cmd
 }
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"slices"
+
+	cv1 "k8s.io/api/core/v1"
+)
+
+// isBestEffort reports whether pod has BestEffort QoS class
+func isBestEffort(pod *cv1.ResourceRequirements) bool {
+	for _, rl := range []cv1.ResourceList{pod.Limits, pod.Requests} {
+		for _, r := range []cv1.ResourceName{cv1.ResourceCPU, cv1.ResourceMemory} {
+			if v, ok := rl[r]; ok && !v.IsZero() {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// hasCrossNamespaceAffinity reports whether pod affinity terms select pods in other namespaces
+func hasCrossNamespaceAffinity(affinity *cv1.Affinity) bool {
+	if affinity == nil {
+		return false
+	}
+	terms := []cv1.PodAffinityTerm{}
+	if a := affinity.PodAffinity; a != nil {
+		terms = append(terms, a.RequiredDuringSchedulingIgnoredDuringExecution...)
+		for _, t := range a.PreferredDuringSchedulingIgnoredDuringExecution {
+			terms = append(terms, t.PodAffinityTerm)
+		}
+	}
+	if a := affinity.PodAntiAffinity; a != nil {
+		terms = append(terms, a.RequiredDuringSchedulingIgnoredDuringExecution...)
+		for _, t := range a.PreferredDuringSchedulingIgnoredDuringExecution {
+			terms = append(terms, t.PodAffinityTerm)
+		}
+	}
+	for _, t := range terms {
+		if len(t.Namespaces) > 0 || t.NamespaceSelector != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// quotaScope returns filter of workloads charged by resource quota, nil if quota applies to everything
+func quotaScope(rq cv1.ResourceQuota) func(Workload) bool {
+	selector := []cv1.ScopedResourceSelectorRequirement{}
+	for _, s := range rq.Spec.Scopes {
+		selector = append(selector, cv1.ScopedResourceSelectorRequirement{ScopeName: s, Operator: cv1.ScopeSelectorOpExists})
+	}
+	if rq.Spec.ScopeSelector != nil {
+		selector = append(selector, rq.Spec.ScopeSelector.MatchExpressions...)
+	}
+	if len(selector) == 0 {
+		return nil
+	}
+	return func(w Workload) bool {
+		for _, s := range selector {
+			if !scopeMatches(w, s) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+func scopeMatches(w Workload, s cv1.ScopedResourceSelectorRequirement) bool {
+	switch s.ScopeName {
+	case cv1.ResourceQuotaScopeTerminating:
+		return w.Terminating
+	case cv1.ResourceQuotaScopeNotTerminating:
+		return !w.Terminating
+	case cv1.ResourceQuotaScopeBestEffort:
+		return w.BestEffort
+	case cv1.ResourceQuotaScopeNotBestEffort:
+		return !w.BestEffort
+	case cv1.ResourceQuotaScopeCrossNamespacePodAffinity:
+		return w.CrossNamespaceAffinity
+	case cv1.ResourceQuotaScopePriorityClass:
+		switch s.Operator {
+		case cv1.ScopeSelectorOpIn:
+			return slices.Contains(s.Values, w.PriorityClass)
+		case cv1.ScopeSelectorOpNotIn:
+			return !slices.Contains(s.Values, w.PriorityClass)
+		case cv1.ScopeSelectorOpExists:
+			return w.PriorityClass != ""
+		case cv1.ScopeSelectorOpDoesNotExist:
+			return w.PriorityClass == ""
+		}
+	}
+	return false
+}
@@ -0,0 +1,249 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/cli/values"
+	"helm.sh/helm/v3/pkg/getter"
+	"helm.sh/helm/v3/pkg/registry"
+)
+
+const (
+	// engineAuto renders with Helm SDK and falls back to HELM_BIN when it fails
+	engineAuto = "auto"
+	engineSDK  = "sdk"
+	engineExec = "exec"
+
+	defaultReleaseName = "release-name"
+)
+
+// helmSettings returns helm environment targeting the same cluster as quota client
+func helmSettings(namespace string) *cli.EnvSettings {
+	settings := cli.New()
+	if namespace != "" {
+		settings.SetNamespace(namespace)
+	}
+	if kube.kubeconfig != "" {
+		settings.KubeConfig = kube.kubeconfig
+	}
+	settings.KubeContext = kube.context
+	settings.KubeAPIServer = kube.apiServer
+	settings.KubeToken = kube.token
+	settings.KubeCaFile = kube.caFile
+	settings.KubeInsecureSkipTLSVerify = kube.insecure
+	settings.KubeTLSServerName = kube.tlsServerName
+	settings.KubeAsUser = kube.asUser
+	settings.KubeAsGroups = kube.asGroups
+	return settings
+}
+
+func debugLog(format string, v ...interface{}) {}
+
+// newRegistryClient returns client pulling oci:// charts with credentials of helm registry login
+func newRegistryClient(settings *cli.EnvSettings) (*registry.Client, error) {
+	return registry.NewClient(
+		registry.ClientOptDebug(settings.Debug),
+		registry.ClientOptEnableCache(true),
+		registry.ClientOptWriter(os.Stderr),
+		registry.ClientOptCredentialsFile(settings.RegistryConfig),
+	)
+}
+
+// sdkTemplate renders chart in-process the way helm template does, hooks included
+func (b baseHelmCmd) sdkTemplate() ([]byte, error) {
+	settings := helmSettings(b.namespace)
+	rc, err := newRegistryClient(settings)
+	if err != nil {
+		return nil, err
+	}
+	cfg := action.Configuration{Log: debugLog, RegistryClient: rc}
+
+	client := action.NewInstall(&cfg)
+	client.SetRegistryClient(rc)
+	client.DryRun = true
+	client.ClientOnly = true
+	client.Replace = true
+	client.ReleaseName = defaultReleaseName
+	if b.releaseName != "" {
+		client.ReleaseName = b.releaseName
+	}
+	client.Namespace = settings.Namespace()
+	client.Version = b.version
+	client.RepoURL = b.repo
+	client.IncludeCRDs = b.includeCRDs
+	client.APIVersions = chartutil.VersionSet(b.apiVersions)
+	if b.kubeVersion != "" {
+		kv, err := chartutil.ParseKubeVersion(b.kubeVersion)
+		if err != nil {
+			return nil, fmt.Errorf("invalid kube version %s: %w", b.kubeVersion, err)
+		}
+		client.KubeVersion = kv
+	}
+
+	cp, err := client.ChartPathOptions.LocateChart(b.chart, settings)
+	if err != nil {
+		return nil, err
+	}
+	ch, err := loader.Load(cp)
+	if err != nil {
+		return nil, err
+	}
+	if req := ch.Metadata.Dependencies; req != nil {
+		if err := action.CheckDependencies(ch, req); err != nil {
+			return nil, fmt.Errorf("%s: %w", b.chart, err)
+		}
+	}
+
+	opts := values.Options{
+		Values:       b.values,
+		ValueFiles:   b.valueFiles,
+		StringValues: b.stringValues,
+		FileValues:   b.fileValues,
+		JSONValues:   b.jsonValues,
+	}
+	vals, err := opts.MergeValues(getter.All(settings))
+	if err != nil {
+		return nil, err
+	}
+	rel, err := client.Run(ch, vals)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := bytes.Buffer{}
+	fmt.Fprintln(&manifest, strings.TrimSpace(rel.Manifest))
+	for _, h := range rel.Hooks {
+		fmt.Fprintf(&manifest, "---\n# Source: %s\n%s\n", h.Path, h.Manifest)
+	}
+	if len(b.showOnly) > 0 {
+		return showOnly(manifest.Bytes(), b.showOnly)
+	}
+	return manifest.Bytes(), nil
+}
+
+var manifestSource = regexp.MustCompile("# Source: [^/]+/(.+)")
+
+// showOnly keeps documents rendered from templates matching patterns, e.g. templates/deployment.yaml
+func showOnly(manifest []byte, patterns []string) ([]byte, error) {
+	docs, err := splitManifest(manifest)
+	if err != nil {
+		return nil, err
+	}
+	res := bytes.Buffer{}
+	for _, pattern := range patterns {
+		missing := true
+		for _, doc := range docs {
+			source := manifestSource.FindSubmatch(doc)
+			if source == nil {
+				continue
+			}
+			if matched, _ := filepath.Match(filepath.ToSlash(pattern), string(source[1])); !matched {
+				continue
+			}
+			fmt.Fprintf(&res, "---\n%s\n", bytes.TrimSpace(bytes.TrimPrefix(bytes.TrimSpace(doc), []byte("---"))))
+			missing = false
+		}
+		if missing {
+			return nil, fmt.Errorf("could not find template %s in chart", pattern)
+		}
+	}
+	return res.Bytes(), nil
+}
+
+// sdkRelease returns manifest of deployed release the way helm get manifest does
+func sdkRelease(release, namespace string) ([]byte, error) {
+	settings := helmSettings(namespace)
+	cfg := action.Configuration{}
+	if err := cfg.Init(settings.RESTClientGetter(), settings.Namespace(), os.Getenv("HELM_DRIVER"), debugLog); err != nil {
+		return nil, err
+	}
+	rel, err := action.NewGet(&cfg).Run(release)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(rel.Manifest), nil
+}
+
+// sdkReleases returns releases listed the way helm list does, across all namespaces when all is set
+func sdkReleases(namespace string, all bool) ([]deployedRelease, error) {
+	settings := helmSettings(namespace)
+	ns := settings.Namespace()
+	if all {
+		ns = ""
+	}
+	cfg := action.Configuration{}
+	if err := cfg.Init(settings.RESTClientGetter(), ns, os.Getenv("HELM_DRIVER"), debugLog); err != nil {
+		return nil, err
+	}
+	client := action.NewList(&cfg)
+	client.AllNamespaces = all
+	client.SetStateMask()
+	rels, err := client.Run()
+	if err != nil {
+		return nil, err
+	}
+	res := []deployedRelease{}
+	for _, rel := range rels {
+		dr := deployedRelease{Name: rel.Name, Namespace: rel.Namespace, Manifest: []byte(rel.Manifest)}
+		if rel.Chart != nil && rel.Chart.Metadata != nil {
+			dr.Chart = rel.Chart.Metadata.Name + "-" + rel.Chart.Metadata.Version
+		}
+		res = append(res, dr)
+	}
+	return res, nil
+}
+
+// render runs SDK or helm binary engine, auto engine falls back to helm binary when available and reports errors of both
+func render[T any](engine string, sdk func() (T, error), exec func() (T, error)) (T, error) {
+	switch engine {
+	case engineSDK:
+		return sdk()
+	case engineExec:
+		return exec()
+	case "", engineAuto:
+		res, err := sdk()
+		if err != nil && os.Getenv("HELM_BIN") != "" {
+			res, execErr := exec()
+			if execErr == nil {
+				return res, nil
+			}
+			return res, errors.Join(err, fmt.Errorf("helm binary fallback: %w", execErr))
+		}
+		return res, err
+	}
+	var none T
+	return none, fmt.Errorf("unknown engine %s", engine)
+}
+
+// renderTemplate renders local chart
+func (b baseHelmCmd) renderTemplate() ([]byte, error) {
+	return render(b.engine, b.sdkTemplate, b.getTemplate)
+}
+
+// releaseManifest fetches manifest of deployed release
+func (b baseHelmCmd) releaseManifest(release string) ([]byte, error) {
+	return render(b.engine, func() ([]byte, error) {
+		return sdkRelease(release, b.namespace)
+	}, func() ([]byte, error) {
+		return getRelease(release, b.namespace)
+	})
+}
+
+// listReleases returns deployed releases of namespace or of all namespaces
+func (b baseHelmCmd) listReleases(all bool) ([]deployedRelease, error) {
+	return render(b.engine, func() ([]deployedRelease, error) {
+		return sdkReleases(b.namespace, all)
+	}, func() ([]deployedRelease, error) {
+		return execReleases(b.namespace, all)
+	})
+}
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	cv1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	scPrefix           = "x-sc-"
+	storageClassSuffix = ".storageclass.storage.k8s.io/"
+
+	defaultStorageClassAnnotation     = "storageclass.kubernetes.io/is-default-class"
+	betaDefaultStorageClassAnnotation = "storageclass.beta.kubernetes.io/is-default-class"
+)
+
+// storageClasses returns sorted names of storage classes claimed in requirements
+func storageClasses(req *Requirements) []string {
+	classes := []string{}
+	for r := range req.Requests {
+		if strings.HasPrefix(string(r), scPrefix) {
+			classes = append(classes, strings.TrimPrefix(string(r), scPrefix))
+		}
+	}
+	sort.Strings(classes)
+	return classes
+}
+
+// storageClassQuota returns storage class scoped quota resource name
+func storageClassQuota(class string, r cv1.ResourceName) cv1.ResourceName {
+	return cv1.ResourceName(class + storageClassSuffix + string(r))
+}
+
+// GetDefaultStorageClass returns name of cluster default storage class or empty string if there is none
+func GetDefaultStorageClass() (string, error) {
+	clientset, err := newClientset()
+	if err != nil {
+		return "", err
+	}
+	scl, err := clientset.StorageV1().StorageClasses().List(context.TODO(), v1.ListOptions{})
+	if err != nil {
+		return "", err
+	}
+	for _, sc := range scl.Items {
+		if sc.Annotations[defaultStorageClassAnnotation] == "true" || sc.Annotations[betaDefaultStorageClassAnnotation] == "true" {
+			return sc.Name, nil
+		}
+	}
+	return "", nil
+}
+
+// discoverDefaultStorageClass returns lookup of cluster default storage class made on first use, so clusters are not
+// queried for charts without claims. Lookup errors, e.g. Forbidden for users limited to their namespace, are reported
+// as warning and mean there is no default class.
+func discoverDefaultStorageClass() func() string {
+	var once sync.Once
+	class := ""
+	return func() string {
+		once.Do(func() {
+			var err error
+			if class, err = GetDefaultStorageClass(); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: could not discover default storage class, set --default-storage-class: %v\n", err)
+			}
+		})
+		return class
+	}
+}
@@ -5,9 +5,12 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sort"
+	"strings"
 
 	"github.com/spf13/cobra"
 	cv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 )
 
 type sumCmd struct {
@@ -23,23 +26,60 @@ func newSumCommand() *cobra.Command {
 		Short: "Show resource summary",
 		Long:  rootCmdLongUsage,
 		Args: func(cmd *cobra.Command, args []string) error {
-			if len(args) == 0 {
-				return errors.New("requires an argument: chart path or release name")
+			if len(args) == 0 && len(sum.manifests) == 0 {
+				return errors.New("requires an argument: chart path or release name, or --manifest")
 			}
 			return nil
 		},
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return validateOutput(sum.output, outputText, outputTable, outputJSON, outputYAML)
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			sum.chart = args[0]
+			if len(args) > 0 {
+				sum.chart = args[0]
+			}
 			return sum.run()
 		},
 	}
 	sum.propogateCmdFlags(cmd)
 	f := cmd.Flags()
-	f.StringVar(&sum.output, "output", "", "Output format")
+	f.Lookup("scale").Usage = "Replicas of autoscaled workloads: current, min or max of HorizontalPodAutoscaler range, all reports each of them"
+	f.StringVar(&sum.output, "output", "", "Output format: text (default), table, json or yaml")
 	return cmd
 }
 
 func (s sumCmd) run() error {
+	if err := s.loadWorkloads(); err != nil {
+		return err
+	}
+	if s.scale == scaleAll {
+		manifest, err := s.GetManifest()
+		if err != nil {
+			return err
+		}
+		list := ReportList{APIVersion: reportAPIVersion, Kind: "SummaryList", Items: []Report{}}
+		for _, scale := range []string{scaleMin, scaleCurrent, scaleMax} {
+			s.scale = scale
+			req, err := s.Parse(manifest)
+			if err != nil {
+				return err
+			}
+			if isStructuredOutput(s.output) {
+				list.Items = append(list.Items, newReport("Summary", scale, req))
+				continue
+			}
+			if _, err := fmt.Fprintf(os.Stdout, "Replicas: %s\n", scale); err != nil {
+				return err
+			}
+			if err := s.FormatOutput(os.Stdout, req); err != nil {
+				return err
+			}
+		}
+		if isStructuredOutput(s.output) {
+			return writeReport(os.Stdout, s.output, list)
+		}
+		return nil
+	}
 	if req, err := s.GetRequirements(); err != nil {
 		return err
 	} else {
@@ -47,25 +87,112 @@ func (s sumCmd) run() error {
 	}
 }
 
-func (s sumCmd) FormatOutput(w io.Writer, req *cv1.ResourceRequirements) error {
-	jobCpuReq := req.Requests[jobCpu]
-	jobCpuLim := req.Limits[jobCpu]
-	jobMemReq := req.Requests[jobMemory]
-	jobMemLim := req.Limits[jobMemory]
-
-	sumCpuReq := req.Requests.Cpu().DeepCopy()
-	sumCpuReq.Add(jobCpuReq)
-	sumMemReq := req.Requests.Memory().DeepCopy()
-	sumMemReq.Add(jobMemReq)
-	sumCpuLim := req.Limits.Cpu().DeepCopy()
-	sumCpuLim.Add(jobCpuLim)
-	sumMemLim := req.Limits.Memory().DeepCopy()
-	sumMemLim.Add(jobMemLim)
+// resourceRow is a compute resource reported in sum and check outputs
+type resourceRow struct {
+	title    string
+	limit    bool
+	resource cv1.ResourceName
+	quota    cv1.ResourceName
+}
+
+var computeRows = []resourceRow{
+	{"CPU Limit", true, cv1.ResourceCPU, cv1.ResourceLimitsCPU},
+	{"Memory Limit", true, cv1.ResourceMemory, cv1.ResourceLimitsMemory},
+	{"Ephemeral Limit", true, cv1.ResourceEphemeralStorage, cv1.ResourceLimitsEphemeralStorage},
+	{"CPU Request", false, cv1.ResourceCPU, cv1.ResourceRequestsCPU},
+	{"Memory Request", false, cv1.ResourceMemory, cv1.ResourceRequestsMemory},
+	{"Ephemeral Request", false, cv1.ResourceEphemeralStorage, cv1.ResourceRequestsEphemeralStorage},
+}
+
+// extendedRows returns rows of extended resources found in requirements, e.g. nvidia.com/gpu or hugepages-2Mi.
+// Quota supports only requests of extended resources.
+func extendedRows(req *Requirements) []resourceRow {
+	names := []string{}
+	seen := map[string]bool{}
+	for r := range req.Requests {
+		n := string(r)
+		for _, prefix := range []string{jobPrefix, dsPrefix, surgePrefix} {
+			n = strings.TrimPrefix(n, prefix)
+		}
+		if isExtendedResource(cv1.ResourceName(n)) && !seen[n] {
+			seen[n] = true
+			names = append(names, n)
+		}
+	}
+	sort.Strings(names)
+
+	rows := []resourceRow{}
+	for _, n := range names {
+		rows = append(rows, resourceRow{n, false, cv1.ResourceName(n), cv1.ResourceName(cv1.DefaultResourceRequestsPrefix + n)})
+	}
+	return rows
+}
+
+// reportRows returns compute and extended resource rows
+func reportRows(req *Requirements) []resourceRow {
+	return append(append([]resourceRow{}, computeRows...), extendedRows(req)...)
+}
+
+var storageRow = resourceRow{"Storage Request", false, cv1.ResourceStorage, cv1.ResourceRequestsStorage}
+
+// storageClassRows returns storage rows of claimed storage classes
+func storageClassRows(req *Requirements) []resourceRow {
+	rows := []resourceRow{}
+	for _, class := range storageClasses(req) {
+		rows = append(rows, resourceRow{class + " Storage", false, cv1.ResourceName(scPrefix + class), storageClassQuota(class, cv1.ResourceRequestsStorage)})
+	}
+	return rows
+}
 
+// resourceRows returns compute, extended and storage resource rows
+func resourceRows(req *Requirements) []resourceRow {
+	return append(append(reportRows(req), storageRow), storageClassRows(req)...)
+}
+
+// countRows returns object count rows, including claims per storage class
+func countRows(req *Requirements) []resourceRow {
+	rows := []resourceRow{}
+	for _, r := range []cv1.ResourceName{cv1.ResourceConfigMaps, cv1.ResourceSecrets, cv1.ResourceServices, cv1.ResourcePersistentVolumeClaims} {
+		rows = append(rows, resourceRow{string(r), true, r, r})
+	}
+	for _, class := range storageClasses(req) {
+		rows = append(rows, resourceRow{class + " pvc", true, cv1.ResourceName(scPrefix + class), storageClassQuota(class, cv1.ResourcePersistentVolumeClaims)})
+	}
+	return rows
+}
+
+func (r resourceRow) list(req *Requirements) cv1.ResourceList {
+	if r.limit {
+		return req.Limits
+	}
+	return req.Requests
+}
+
+// totals splits resource into static workload, DaemonSet and Job parts and returns them with their sum
+func totals(rl cv1.ResourceList, r cv1.ResourceName) (static, ds, job, sum resource.Quantity) {
+	static = rl[r]
+	ds = rl[cv1.ResourceName(dsPrefix+string(r))]
+	job = rl[cv1.ResourceName(jobPrefix+string(r))]
+	sum = static.DeepCopy()
+	sum.Add(ds)
+	sum.Add(job)
+	return
+}
+
+// running returns part of resource consumed by permanently running workloads
+func running(rl cv1.ResourceList, r cv1.ResourceName) resource.Quantity {
+	v := rl[r].DeepCopy()
+	v.Add(rl[cv1.ResourceName(dsPrefix+string(r))])
+	return v
+}
+
+func (s sumCmd) FormatOutput(w io.Writer, req *Requirements) error {
 	switch s.output {
-	case "table":
+	case outputJSON, outputYAML:
+		return writeReport(w, s.output, newReport("Summary", s.scale, req))
+	case outputTable:
 		line := func() error {
-			if _, err := fmt.Fprint(w, "+---------------+---------------+---------------+---------------+\n"); err != nil {
+			if _, err := fmt.Fprint(w, "+------------------+---------------+---------------+---------------+---------------+\n"); err != nil {
 				return err
 			}
 			return nil
@@ -73,42 +200,57 @@ func (s sumCmd) FormatOutput(w io.Writer, req *cv1.ResourceRequirements) error {
 		if err := line(); err != nil {
 			return err
 		}
-		if _, err := fmt.Fprint(w, "|               | Static wrkld  | Jobs          | Sum           |\n"); err != nil {
+		if _, err := fmt.Fprint(w, "|                  | Static wrkld  | DaemonSets    | Jobs          | Sum           |\n"); err != nil {
 			return err
 		}
 		if err := line(); err != nil {
 			return err
 		}
-		if _, err := fmt.Fprintf(w, "|CPU Limit      | %13v | %13v | %13v |\n", req.Limits.Cpu(), &jobCpuLim, &sumCpuLim); err != nil {
-			return err
+		for _, r := range append(reportRows(req), storageRow) {
+			static, ds, job, sum := totals(r.list(req), r.resource)
+			if _, err := fmt.Fprintf(w, "|%-18.18s| %13v | %13v | %13v | %13v |\n", r.title, &static, &ds, &job, &sum); err != nil {
+				return err
+			}
 		}
-		if _, err := fmt.Fprintf(w, "|Memory Limit   | %13v | %13v | %13v |\n", req.Limits.Memory(), &jobMemLim, &sumMemLim); err != nil {
-			return err
+		for _, class := range storageClasses(req) {
+			storage := req.Requests[cv1.ResourceName(scPrefix+class)]
+			if _, err := fmt.Fprintf(w, "|%-18.18s|               |               |               | %13v |\n", class+" Storage", &storage); err != nil {
+				return err
+			}
 		}
-		if _, err := fmt.Fprintf(w, "|CPU Request    | %13v | %13v | %13v |\n", req.Requests.Cpu(), &jobCpuReq, &sumCpuReq); err != nil {
+		pvc := req.Limits[cv1.ResourcePersistentVolumeClaims]
+		if _, err := fmt.Fprintf(w, "|%-18.18s|               |               |               | %13v |\n", "PVC Count", &pvc); err != nil {
 			return err
 		}
-		if _, err := fmt.Fprintf(w, "|Memory Request | %13v | %13v | %13v |\n", req.Requests.Memory(), &jobMemReq, &sumMemReq); err != nil {
-			return err
+		for _, class := range storageClasses(req) {
+			pvc := req.Limits[cv1.ResourceName(scPrefix+class)]
+			if _, err := fmt.Fprintf(w, "|%-18.18s|               |               |               | %13v |\n", class+" PVC Count", &pvc); err != nil {
+				return err
+			}
 		}
 		if err := line(); err != nil {
 			return err
 		}
 	default:
-		if _, err := fmt.Fprintf(w, "CPU Limit %v + %v (Jobs) = %v\n", req.Limits.Cpu(), &jobCpuLim, &sumCpuLim); err != nil {
-			return err
-		}
-		if _, err := fmt.Fprintf(w, "Memory Limit %v + %v (Jobs) = %v\n", req.Limits.Memory(), &jobMemLim, &sumMemLim); err != nil {
-			return err
+		for _, r := range reportRows(req) {
+			static, ds, job, sum := totals(r.list(req), r.resource)
+			if _, err := fmt.Fprintf(w, "%s %v + %v (DaemonSets) + %v (Jobs) = %v\n", r.title, &static, &ds, &job, &sum); err != nil {
+				return err
+			}
 		}
-		if _, err := fmt.Fprintf(w, "CPU Request %v + %v (Jobs) = %v\n", req.Requests.Cpu(), &jobCpuReq, &sumCpuReq); err != nil {
+		storage := req.Requests[cv1.ResourceStorage]
+		pvc := req.Limits[cv1.ResourcePersistentVolumeClaims]
+		if _, err := fmt.Fprintf(w, "Storage Request %v (%v PVC)\n", &storage, &pvc); err != nil {
 			return err
 		}
-		if _, err := fmt.Fprintf(w, "Memory Request %v + %v (Jobs) = %v\n", req.Requests.Memory(), &jobMemReq, &sumMemReq); err != nil {
-			return err
+		for _, class := range storageClasses(req) {
+			storage := req.Requests[cv1.ResourceName(scPrefix+class)]
+			pvc := req.Limits[cv1.ResourceName(scPrefix+class)]
+			if _, err := fmt.Fprintf(w, "Storage Request %s %v (%v PVC)\n", class, &storage, &pvc); err != nil {
+				return err
+			}
 		}
-
 	}
 
-	return nil
+	return printViolations(w, req)
 }
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	appsv1 "k8s.io/api/apps/v1"
+	cv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const surgePrefix = "x-surge-"
+
+var defaultMaxSurge = intstr.FromString("25%")
+
+// deploymentSurge returns number of extra pods Deployment creates during rolling update
+func deploymentSurge(depl appsv1.Deployment, repl int32) (int32, error) {
+	if depl.Spec.Strategy.Type == appsv1.RecreateDeploymentStrategyType {
+		return 0, nil
+	}
+	maxSurge := &defaultMaxSurge
+	if depl.Spec.Strategy.RollingUpdate != nil && depl.Spec.Strategy.RollingUpdate.MaxSurge != nil {
+		maxSurge = depl.Spec.Strategy.RollingUpdate.MaxSurge
+	}
+	surge, err := intstr.GetScaledValueFromIntOrPercent(maxSurge, int(repl), true)
+	return int32(surge), err
+}
+
+// daemonSetSurge returns number of extra pods DaemonSet creates during rolling update
+func daemonSetSurge(ds appsv1.DaemonSet, nodes int32) (int32, error) {
+	if ds.Spec.UpdateStrategy.RollingUpdate == nil || ds.Spec.UpdateStrategy.RollingUpdate.MaxSurge == nil {
+		return 0, nil
+	}
+	if ds.Spec.UpdateStrategy.Type == appsv1.OnDeleteDaemonSetStrategyType {
+		return 0, nil
+	}
+	surge, err := intstr.GetScaledValueFromIntOrPercent(ds.Spec.UpdateStrategy.RollingUpdate.MaxSurge, int(nodes), true)
+	return int32(surge), err
+}
+
+// rollout returns resource sum increased by surge pods
+func rollout(rl cv1.ResourceList, r cv1.ResourceName) resource.Quantity {
+	_, _, _, sum := totals(rl, r)
+	sum.Add(rl[cv1.ResourceName(surgePrefix+string(r))])
+	return sum
+}